@@ -1,13 +1,19 @@
 package builder
 
 import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"html/template"
 	"io/ioutil"
 	"os"
+	"path"
 	"path/filepath"
-	"runtime"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/pkg/errors"
 
@@ -15,27 +21,42 @@ import (
 	"github.com/suborbital/subo/subo/util"
 )
 
-var dockerImageForLang = map[string]string{
-	"rust":           "suborbital/builder-rs",
-	"swift":          "suborbital/builder-swift",
-	"assemblyscript": "suborbital/builder-as",
-	"tinygo":         "suborbital/builder-tinygo",
-	"grain":          "--platform linux/amd64 suborbital/builder-gr",
-	"typescript":     "suborbital/builder-js",
-	"javascript":     "suborbital/builder-js",
-	"wat":            "suborbital/builder-wat",
-}
-
 // BuildConfig is the configuration for a Builder.
 type BuildConfig struct {
 	JsToolchain   string
 	CommandRunner util.CommandRunner
+	// Jobs is the maximum number of modules built at the same time by the native toolchain.
+	Jobs int
+	// Timeout is the longest a single module's build may take, with zero meaning no limit.
+	Timeout time.Duration
+	// UseCache enables restoring unchanged modules from the build cache rather than rebuilding them.
+	UseCache bool
+	// ValidateWasm enables checking that each built module is valid Wasm with the exports E2Core requires.
+	ValidateWasm bool
+	// Optimize enables stripping custom sections from every module after it is built.
+	Optimize bool
+	// EmbedMetadata enables embedding build provenance (git commit, builder tag etc) into each module. It is off by
+	// default, as the git commit changes every module's bytes (and so its ref) on every commit.
+	EmbedMetadata bool
+	// Offline prevents prereqs from being fetched from the network, resolving them from MirrorDir
+	// or with their offline commands (e.g. installing from the package manager's cache) instead.
+	Offline bool
+	// MirrorDir is the local directory that prereqs are copied from in offline builds.
+	// If empty, the subo/mirror directory within the user's cache directory is used.
+	MirrorDir string
+	// ContainerEngine runs the builder images for the Docker toolchain. If nil, util.Engine is used.
+	ContainerEngine *util.ContainerEngine
+	// APIVersion overrides the API version in every module's .module.yaml if set.
+	APIVersion string
 }
 
 // DefaultBuildConfig is the default build configuration.
 var DefaultBuildConfig = BuildConfig{
 	JsToolchain:   "npm",
 	CommandRunner: util.Command,
+	Jobs:          1,
+	UseCache:      true,
+	ValidateWasm:  true,
 }
 
 // Builder is capable of building Wasm modules from source.
@@ -44,14 +65,34 @@ type Builder struct {
 	Config  *BuildConfig
 
 	results []BuildResult
+	cache   *BuildCache
+
+	// toolVersions are the versions of each language's native tools, gathered when they're first needed.
+	toolVersionsLock sync.Mutex
+	toolVersions     map[string]string
 
 	log util.FriendlyLogger
 }
 
 // BuildResult is the results of a build including the built module and logs.
 type BuildResult struct {
+	Name      string
+	Namespace string
+	Lang      string
+	Toolchain Toolchain
 	Succeeded bool
-	OutputLog string
+	CacheHit  bool
+	Duration  time.Duration
+	WasmPath  string
+	Size      int64
+	// UnoptimizedSize is the size of the module before optimization, or 0 if it was not optimized.
+	UnoptimizedSize int64
+	Ref             string
+	OutputLog       string
+	// ErrorLog is the stderr of the build's commands, which is also included in OutputLog.
+	ErrorLog string
+	// Error is why the module failed to build, if it did.
+	Error string
 }
 
 type Toolchain string
@@ -61,6 +102,9 @@ const (
 	ToolchainDocker = Toolchain("docker")
 )
 
+// containerMirrorDir is where the offline mirror is mounted in builder containers.
+const containerMirrorDir = "/root/mirror"
+
 // ForDirectory creates a Builder bound to a particular directory.
 func ForDirectory(logger util.FriendlyLogger, config *BuildConfig, dir string) (*Builder, error) {
 	ctx, err := project.ForDirectory(dir)
@@ -68,6 +112,11 @@ func ForDirectory(logger util.FriendlyLogger, config *BuildConfig, dir string) (
 		return nil, errors.Wrap(err, "failed to project.ForDirectory")
 	}
 
+	return ForContext(logger, config, ctx), nil
+}
+
+// ForContext creates a Builder for an already loaded project context.
+func ForContext(logger util.FriendlyLogger, config *BuildConfig, ctx *project.Context) *Builder {
 	b := &Builder{
 		Context: ctx,
 		Config:  config,
@@ -75,70 +124,397 @@ func ForDirectory(logger util.FriendlyLogger, config *BuildConfig, dir string) (
 		log:     logger,
 	}
 
-	return b, nil
+	return b
 }
 
-func (b *Builder) BuildWithToolchain(tcn Toolchain) error {
-	var err error
+// BuildWithToolchain builds all of the modules in the builder's context using the given toolchain.
+// Up to Config.Jobs modules are built at the same time, and every failure is reported
+// together once all of the modules have been attempted. Cancelling ctx stops any running
+// build commands and containers, and modules which haven't started yet are not built.
+func (b *Builder) BuildWithToolchain(ctx context.Context, tcn Toolchain) error {
+	return b.BuildModulesWithToolchain(ctx, tcn, b.Context.Modules)
+}
 
+// BuildModulesWithToolchain builds the given subset of the context's modules using the given toolchain.
+func (b *Builder) BuildModulesWithToolchain(ctx context.Context, tcn Toolchain, mods []project.ModuleDir) error {
 	b.results = []BuildResult{}
 
-	// When building in Docker mode, just collect the langs we need to build, and then
-	// launch the associated builder images which will do the building.
-	dockerLangs := map[string]bool{}
+	toBuild := []project.ModuleDir{}
 
-	for _, mod := range b.Context.Modules {
-		if !b.Context.ShouldBuildLang(mod.Module.Lang) {
-			continue
+	for _, mod := range mods {
+		if b.Context.ShouldBuildLang(mod.Module.Lang) {
+			toBuild = append(toBuild, mod)
 		}
+	}
+
+	// Modules are built after the modules they depend on.
+	toBuild, err := project.SortModules(toBuild)
+	if err != nil {
+		return errors.Wrap(err, "failed to SortModules")
+	}
+
+	if b.Config.APIVersion != "" {
+		toBuild = withAPIVersion(toBuild, b.Config.APIVersion)
+	}
+
+	if tcn == ToolchainNative && b.Config.UseCache && b.cache == nil {
+		cache, err := NewBuildCache()
+		if err != nil {
+			b.log.LogWarn(fmt.Sprintf("build cache disabled: %s", err.Error()))
+		} else {
+			b.cache = cache
+		}
+	}
+
+	return b.buildModules(ctx, tcn, toBuild)
+}
+
+// withAPIVersion returns copies of the modules with the given API version, leaving the context's modules unchanged.
+func withAPIVersion(mods []project.ModuleDir, apiVersion string) []project.ModuleDir {
+	versioned := make([]project.ModuleDir, len(mods))
+
+	for i, mod := range mods {
+		module := *mod.Module
+		module.APIVersion = apiVersion
+
+		versioned[i] = mod
+		versioned[i].Module = &module
+	}
+
+	return versioned
+}
+
+// buildModules builds the given modules with a bounded pool of workers. Results are
+// stored in the same order as the modules regardless of which finishes first. The modules
+// must already be sorted by dependency, as each one waits for its dependencies to finish.
+func (b *Builder) buildModules(ctx context.Context, tcn Toolchain, mods []project.ModuleDir) error {
+	jobs := b.Config.Jobs
+	if jobs < 1 {
+		jobs = 1
+	}
+
+	results := make([]BuildResult, len(mods))
+	errs := make([]error, len(mods))
+
+	indices := map[string]int{}
+	done := make([]chan struct{}, len(mods))
 
-		if tcn == ToolchainNative {
-			b.log.LogStart(fmt.Sprintf("building module: %s (%s)", mod.Name, mod.Module.Lang))
+	for i, mod := range mods {
+		indices[mod.Key()] = i
+		done[i] = make(chan struct{})
+	}
+
+	sem := make(chan struct{}, jobs)
+	wg := sync.WaitGroup{}
+
+	for i := range mods {
+		wg.Add(1)
+		sem <- struct{}{}
+
+		go func(i int) {
+			defer func() {
+				close(done[i])
+				<-sem
+				wg.Done()
+			}()
+
+			// Dependencies come earlier in the list, so they have already started and hold (or held) a slot.
+			for d, dep := range mods[i].DependencyKeys() {
+				j, exists := indices[dep]
+				if !exists {
+					continue
+				}
+
+				<-done[j]
 
-			result := &BuildResult{}
+				if errs[j] != nil {
+					errs[i] = fmt.Errorf("dependency %s failed to build", mods[i].Config.DependsOn[d])
+					describeResult(mods[i], tcn, 0, &results[i])
 
-			if err := b.checkAndRunPreReqs(mod, result); err != nil {
-				return errors.Wrap(err, "🚫 failed to checkAndRunPreReqs")
+					return
+				}
 			}
 
-			if flags, err := b.analyzeForCompilerFlags(mod); err != nil {
-				return errors.Wrap(err, "🚫 failed to analyzeForCompilerFlags")
-			} else if flags != "" {
-				mod.CompilerFlags = flags
+			start := time.Now()
+
+			if ctx.Err() != nil {
+				errs[i] = errors.Wrap(ctx.Err(), "build was cancelled before starting")
+				describeResult(mods[i], tcn, 0, &results[i])
+
+				return
 			}
 
-			err = b.doNativeBuildForModule(mod, result)
+			modCtx, cancel := ctx, context.CancelFunc(func() {})
+			if b.Config.Timeout > 0 {
+				modCtx, cancel = context.WithTimeout(ctx, b.Config.Timeout)
+			}
 
-			// Even if there was a failure, load the result into the builder
-			// since the logs of the failed build are useful.
-			b.results = append(b.results, *result)
+			if tcn == ToolchainNative {
+				errs[i] = b.nativeBuildModule(modCtx, mods[i], &results[i])
+			} else {
+				errs[i] = b.dockerBuildModule(modCtx, mods[i], &results[i])
+			}
 
-			if err != nil {
-				return errors.Wrapf(err, "🚫 failed to build %s", mod.Name)
+			if errs[i] != nil && errors.Is(modCtx.Err(), context.DeadlineExceeded) {
+				results[i].Succeeded = false
+				errs[i] = errors.Wrapf(errs[i], "timed out after %s", b.Config.Timeout)
 			}
 
-			fullWasmFilepath := filepath.Join(mod.Fullpath, fmt.Sprintf("%s.wasm", mod.Name))
-			b.log.LogDone(fmt.Sprintf("%s was built -> %s", mod.Name, fullWasmFilepath))
+			cancel()
 
-		} else {
-			dockerLangs[mod.Module.Lang] = true
+			describeResult(mods[i], tcn, time.Since(start), &results[i])
+		}(i)
+	}
+
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			results[i].Succeeded = false
+			results[i].Error = err.Error()
 		}
 	}
 
-	if tcn == ToolchainDocker {
-		for lang := range dockerLangs {
-			result, err := b.dockerBuildForLang(lang)
-			if err != nil {
-				return errors.Wrap(err, "failed to dockerBuildForDirectory")
+	// Even if there were failures, load the results into the builder
+	// since the logs of the failed builds are useful.
+	b.results = append(b.results, results...)
+
+	failed := []string{}
+	for i, err := range errs {
+		if err != nil {
+			b.log.LogFail(fmt.Sprintf("%s: %s", mods[i].Name, err.Error()))
+			failed = append(failed, mods[i].Name)
+		}
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("🚫 failed to build %d of %d modules: %s", len(failed), len(mods), strings.Join(failed, ", "))
+	}
+
+	return nil
+}
+
+// nativeBuildModule runs the prerequisites and native build commands for a single module.
+func (b *Builder) nativeBuildModule(ctx context.Context, mod project.ModuleDir, result *BuildResult) error {
+	b.log.LogStart(fmt.Sprintf("building module: %s (%s)", mod.Name, mod.Module.Lang))
+
+	meta := b.buildMetadata(mod, ToolchainNative)
+
+	// The cache key is calculated before building since the toolchains
+	// may modify the module directory (lockfiles, vendored dependencies etc).
+	cacheKey := ""
+	if b.cache != nil {
+		key, err := b.cacheKey(mod)
+		if err != nil {
+			b.log.LogWarn(fmt.Sprintf("failed to calculate cache key for %s: %s", mod.Name, err.Error()))
+		} else if hit, err := b.cache.Restore(key, mod); err != nil {
+			b.log.LogWarn(fmt.Sprintf("failed to restore %s from cache: %s", mod.Name, err.Error()))
+		} else if hit {
+			// The cached module's metadata is refreshed since the git commit may have changed.
+			if err := embedMetadata(mod, meta); err != nil {
+				return errors.Wrap(err, "failed to embedMetadata")
 			}
 
-			b.results = append(b.results, *result)
+			result.Succeeded = true
+			result.CacheHit = true
+
+			b.log.LogDone(fmt.Sprintf("%s is unchanged, restored from cache -> %s", mod.Name, wasmPath(mod)))
+
+			return nil
+		} else {
+			cacheKey = key
 		}
 	}
 
+	if err := b.checkAndRunPreReqs(ctx, mod, result); err != nil {
+		return errors.Wrap(err, "failed to checkAndRunPreReqs")
+	}
+
+	if flags, err := b.analyzeForCompilerFlags(mod); err != nil {
+		return errors.Wrap(err, "failed to analyzeForCompilerFlags")
+	} else if flags != "" {
+		mod.CompilerFlags = flags
+	}
+
+	if err := b.doNativeBuildForModule(ctx, mod, result); err != nil {
+		return errors.Wrapf(err, "failed to build %s", mod.Name)
+	}
+
+	if b.Config.ValidateWasm {
+		if err := ValidateWasmFile(mod); err != nil {
+			result.Succeeded = false
+			result.OutputLog += err.Error() + "\n"
+
+			return errors.Wrap(err, "failed to ValidateWasmFile")
+		}
+	}
+
+	if b.shouldOptimize(mod) {
+		before, after, err := OptimizeWasmFile(mod)
+		if err != nil {
+			result.Succeeded = false
+			return errors.Wrap(err, "failed to OptimizeWasmFile")
+		}
+
+		result.UnoptimizedSize = before
+
+		b.log.LogInfo(fmt.Sprintf("optimized %s: %d -> %d bytes (saved %.1f%%)", mod.Name, before, after, savedPercent(before, after)))
+	}
+
+	// The module is cached before its metadata is embedded, since the metadata describes this build
+	// rather than the module's contents and is embedded again whenever it's restored.
+	if cacheKey != "" {
+		if err := b.cache.Store(cacheKey, mod); err != nil {
+			b.log.LogWarn(fmt.Sprintf("failed to store %s in cache: %s", mod.Name, err.Error()))
+		}
+	}
+
+	if err := embedMetadata(mod, meta); err != nil {
+		result.Succeeded = false
+		return errors.Wrap(err, "failed to embedMetadata")
+	}
+
+	b.log.LogDone(fmt.Sprintf("%s was built -> %s", mod.Name, wasmPath(mod)))
+
 	return nil
 }
 
+// cacheKey returns the cache key for a module built with the native toolchain. The keys of the modules it
+// depends on are included so that it is rebuilt (rather than restored) when any of them change.
+func (b *Builder) cacheKey(mod project.ModuleDir) (string, error) {
+	tools, err := b.nativeToolVersions(mod.Module.Lang)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to nativeToolVersions")
+	}
+
+	options := []string{
+		fmt.Sprintf("optimize:%t", b.shouldOptimize(mod)),
+		fmt.Sprintf("apiVersion:%s", mod.Module.APIVersion),
+		fmt.Sprintf("tools:%s", tools),
+	}
+
+	// Dependencies are looked up by key, as modules in other namespaces can have the same name.
+	for i, depKey := range mod.DependencyKeys() {
+		dep := mod.Config.DependsOn[i]
+
+		depMod, exists := b.Context.ModuleByKey(depKey)
+		if !exists {
+			continue
+		}
+
+		depKey, err := b.cacheKey(depMod)
+		if err != nil {
+			return "", errors.Wrapf(err, "failed to calculate cache key for dependency %s", dep)
+		}
+
+		options = append(options, fmt.Sprintf("dependency:%s:%s", dep, depKey))
+	}
+
+	return b.cache.Key(mod, ToolchainNative, b.Context.BuilderTag, options...)
+}
+
+// nativeToolVersions returns the output of the language's version commands, which identify the native tools
+// that build its modules. They are only run once for each language, as they're the same for every module.
+func (b *Builder) nativeToolVersions(lang string) (string, error) {
+	b.toolVersionsLock.Lock()
+	defer b.toolVersionsLock.Unlock()
+
+	if versions, exists := b.toolVersions[lang]; exists {
+		return versions, nil
+	}
+
+	l, exists := project.LanguageFor(lang)
+	if !exists {
+		return "", fmt.Errorf("%s is an unsupported language", lang)
+	}
+
+	// The versions are gathered silently, as they're only of interest to the cache.
+	runner := util.NewCommandLineExecutor(util.SilentOutput, nil)
+	versions := &strings.Builder{}
+
+	for _, cmd := range l.VersionCommands {
+		cmdTmpl, err := template.New("cmd").Parse(cmd)
+		if err != nil {
+			return "", errors.Wrap(err, "failed to Parse version command template")
+		}
+
+		fullCmd := &strings.Builder{}
+		if err := cmdTmpl.Execute(fullCmd, struct{ BuildConfig BuildConfig }{*b.Config}); err != nil {
+			return "", errors.Wrap(err, "failed to Execute version command template")
+		}
+
+		out, err := runner.Run(fullCmd.String())
+		if err != nil {
+			return "", errors.Wrapf(err, "failed to Run %s", fullCmd.String())
+		}
+
+		fmt.Fprintf(versions, "%s\n", strings.TrimSpace(out))
+	}
+
+	if b.toolVersions == nil {
+		b.toolVersions = map[string]string{}
+	}
+
+	b.toolVersions[lang] = versions.String()
+
+	return versions.String(), nil
+}
+
+// buildMetadata returns the module's build metadata if the builder is configured to embed it, or nil. It must be
+// gathered before the module is built, as building changes files (lockfiles, build outputs etc) in its git tree.
+func (b *Builder) buildMetadata(mod project.ModuleDir, tcn Toolchain) *BuildMetadata {
+	if !b.Config.EmbedMetadata {
+		return nil
+	}
+
+	meta := b.metadataForModule(mod, tcn)
+
+	return &meta
+}
+
+// embedMetadata embeds the build metadata into the module, if there is any.
+func embedMetadata(mod project.ModuleDir, meta *BuildMetadata) error {
+	if meta == nil {
+		return nil
+	}
+
+	return EmbedMetadata(mod, *meta)
+}
+
+// savedPercent returns the percentage of the original size saved by optimization.
+func savedPercent(before, after int64) float64 {
+	if before == 0 {
+		return 0
+	}
+
+	return float64(before-after) / float64(before) * 100
+}
+
+// describeResult fills in the details of the module a result belongs to, including
+// the size and sha256 ref of its Wasm file if the build succeeded.
+func describeResult(mod project.ModuleDir, tcn Toolchain, duration time.Duration, result *BuildResult) {
+	result.Name = mod.Name
+	result.Namespace = mod.Module.Namespace
+	result.Lang = mod.Module.Lang
+	result.Toolchain = tcn
+	result.Duration = duration
+
+	if !result.Succeeded {
+		return
+	}
+
+	wasmBytes, err := ioutil.ReadFile(wasmPath(mod))
+	if err != nil {
+		return
+	}
+
+	digest := sha256.Sum256(wasmBytes)
+
+	result.WasmPath = wasmPath(mod)
+	result.Size = int64(len(wasmBytes))
+	result.Ref = hex.EncodeToString(digest[:])
+}
+
 // Results returns build results for all of the modules built by this builder
 // returns os.ErrNotExists if none have been built yet.
 func (b *Builder) Results() ([]BuildResult, error) {
@@ -149,33 +525,109 @@ func (b *Builder) Results() ([]BuildResult, error) {
 	return b.results, nil
 }
 
-func (b *Builder) dockerBuildForLang(lang string) (*BuildResult, error) {
-	img, err := ImageForLang(lang, b.Context.BuilderTag)
+// dockerBuildModule builds a single module by running `subo build --native` for it within its language's builder image.
+func (b *Builder) dockerBuildModule(ctx context.Context, mod project.ModuleDir, result *BuildResult) error {
+	b.log.LogStart(fmt.Sprintf("building module: %s (%s) 🐳", mod.Name, mod.Module.Lang))
+
+	meta := b.buildMetadata(mod, ToolchainDocker)
+
+	img, err := ImageForLang(mod.Module.Lang, b.Context.BuilderTag)
 	if err != nil {
-		return nil, errors.Wrap(err, "failed to ImageForLang")
+		return errors.Wrap(err, "failed to ImageForLang")
+	}
+
+	relModPath, err := filepath.Rel(b.Context.Cwd, mod.Fullpath)
+	if err != nil {
+		return errors.Wrap(err, "failed to determine module path relative to project")
+	}
+
+	containerPath := path.Join(b.Context.RelDockerPath, filepath.ToSlash(relModPath))
+
+	engine := b.Config.ContainerEngine
+	if engine == nil {
+		engine, err = util.Engine()
+		if err != nil {
+			return errors.Wrap(err, "failed to util.Engine")
+		}
+	}
+
+	// The container is named so that it can be removed if the build is cancelled, as
+	// killing the engine's CLI doesn't necessarily stop the container itself.
+	containerName := builderContainerName(mod)
+
+	// The arguments are passed without a shell so that paths containing spaces are mounted correctly.
+	runArgs := []string{"run", "--rm", "--name", containerName, "--mount", fmt.Sprintf("type=bind,source=%s,target=/root/module", b.Context.MountPath)}
+	suboArgs := []string{"subo", "build", containerPath, "--native"}
+
+	if b.Config.JsToolchain != DefaultBuildConfig.JsToolchain {
+		suboArgs = append(suboArgs, "--js-toolchain", b.Config.JsToolchain)
+	}
+
+	if b.Config.APIVersion != "" {
+		// The container's subo reads the API version from .module.yaml, so the override is passed along.
+		suboArgs = append(suboArgs, "--api-version", b.Config.APIVersion)
+	}
+
+	if b.Config.Offline {
+		// The container gets no network at all, and resolves prereqs from the mirror mounted read-only.
+		runArgs = append(runArgs, "--network", "none", "--mount", fmt.Sprintf("type=bind,source=%s,target=%s,readonly", b.mirrorDir(), containerMirrorDir))
+		suboArgs = append(suboArgs, "--offline", "--mirror", containerMirrorDir)
+	}
+
+	if lang, exists := project.LanguageFor(mod.Module.Lang); exists {
+		runArgs = append(runArgs, lang.DockerRunArgs...)
 	}
 
-	result := &BuildResult{}
+	runArgs = append(runArgs, img)
 
-	outputLog, err := b.Config.CommandRunner.Run(fmt.Sprintf("docker run --rm --mount type=bind,source=%s,target=/root/module %s subo build %s --native --langs %s", b.Context.MountPath, img, b.Context.RelDockerPath, lang))
+	if engine.OwnsBindMounts() {
+		// Hand the module's files back to the current user so they aren't left root-owned, even if the build fails.
+		script := fmt.Sprintf("%s; status=$?; chown -R %s %s; exit $status", util.ArgsCmd(suboArgs...), engine.UserSpec(), util.ArgsCmd(containerPath))
+		runArgs = append(runArgs, "sh", "-c", script)
+	} else {
+		runArgs = append(runArgs, suboArgs...)
+	}
 
-	result.OutputLog = outputLog
+	err = b.runCmd(ctx, engine.Cmd(runArgs...), result)
 
 	if err != nil {
 		result.Succeeded = false
-		return nil, errors.Wrap(err, "failed to Run docker command")
+
+		if ctx.Err() != nil {
+			if _, rmErr := b.Config.CommandRunner.Exec(context.Background(), engine.Cmd("rm", "-f", containerName)); rmErr != nil {
+				b.log.LogWarn(fmt.Sprintf("failed to remove builder container %s: %s", containerName, rmErr.Error()))
+			}
+		}
+
+		return errors.Wrapf(err, "failed to Run %s command", engine.Name)
 	}
 
 	result.Succeeded = true
 
-	return result, nil
+	// The builder images only know about .module.yaml settings, so optimization
+	// requested by the build config is done after the fact.
+	if b.Config.Optimize && !mod.Config.Optimize {
+		if before, _, err := OptimizeWasmFile(mod); err != nil {
+			b.log.LogWarn(fmt.Sprintf("failed to optimize %s: %s", mod.Name, err.Error()))
+		} else {
+			result.UnoptimizedSize = before
+		}
+	}
+
+	if err := embedMetadata(mod, meta); err != nil {
+		b.log.LogWarn(fmt.Sprintf("failed to embed metadata in %s: %s", mod.Name, err.Error()))
+	}
+
+	b.log.LogDone(fmt.Sprintf("%s was built -> %s", mod.Name, wasmPath(mod)))
+
+	return nil
 }
 
 // results and resulting file are loaded into the BuildResult pointer.
-func (b *Builder) doNativeBuildForModule(mod project.ModuleDir, result *BuildResult) error {
-	cmds, err := NativeBuildCommands(mod.Module.Lang)
+func (b *Builder) doNativeBuildForModule(ctx context.Context, mod project.ModuleDir, result *BuildResult) error {
+	cmds, err := moduleBuildCommands(mod)
 	if err != nil {
-		return errors.Wrap(err, "failed to NativeBuildCommands")
+		return errors.Wrap(err, "failed to moduleBuildCommands")
 	}
 
 	for _, cmd := range cmds {
@@ -192,13 +644,9 @@ func (b *Builder) doNativeBuildForModule(mod project.ModuleDir, result *BuildRes
 		cmdString := strings.TrimSpace(fullCmd.String())
 
 		// Even if the command fails, still load the output into the result object.
-		outputLog, err := b.Config.CommandRunner.RunInDir(cmdString, mod.Fullpath)
-
-		result.OutputLog += outputLog + "\n"
-
-		if err != nil {
+		if err := b.runCmd(ctx, util.Cmd{Shell: cmdString, Dir: mod.Fullpath}, result); err != nil {
 			result.Succeeded = false
-			return errors.Wrap(err, "failed to RunInDir")
+			return errors.Wrap(err, "failed to runCmd")
 		}
 
 		result.Succeeded = true
@@ -207,26 +655,47 @@ func (b *Builder) doNativeBuildForModule(mod project.ModuleDir, result *BuildRes
 	return nil
 }
 
+// moduleBuildCommands returns the full list of commands to build a module, taking into account
+// the preBuild, build and postBuild commands from its .module.yaml.
+func moduleBuildCommands(mod project.ModuleDir) ([]string, error) {
+	buildCmds := mod.Config.Build
+
+	if len(buildCmds) == 0 {
+		langCmds, err := NativeBuildCommands(mod.Module.Lang)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to NativeBuildCommands")
+		}
+
+		buildCmds = langCmds
+	}
+
+	cmds := []string{}
+	cmds = append(cmds, mod.Config.PreBuild...)
+	cmds = append(cmds, buildCmds...)
+	cmds = append(cmds, mod.Config.PostBuild...)
+
+	return cmds, nil
+}
+
 // ImageForLang returns the Docker image:tag builder for the given language.
 func ImageForLang(lang, tag string) (string, error) {
-	img, ok := dockerImageForLang[lang]
-	if !ok {
+	l, ok := project.LanguageFor(lang)
+	if !ok || l.DockerImage == "" {
 		return "", fmt.Errorf("%s is an unsupported language", lang)
 	}
 
-	return fmt.Sprintf("%s:%s", img, tag), nil
+	return fmt.Sprintf("%s:%s", l.DockerImage, tag), nil
 }
 
-func (b *Builder) checkAndRunPreReqs(module project.ModuleDir, result *BuildResult) error {
-	preReqLangs, ok := PreRequisiteCommands[runtime.GOOS]
-	if !ok {
-		return fmt.Errorf("unsupported OS: %s", runtime.GOOS)
+func (b *Builder) checkAndRunPreReqs(ctx context.Context, module project.ModuleDir, result *BuildResult) error {
+	preReqs, err := PreRequisites(module.Module.Lang)
+	if err != nil {
+		return errors.Wrap(err, "failed to PreRequisites")
 	}
 
-	preReqs, ok := preReqLangs[module.Module.Lang]
-	if !ok {
-		return fmt.Errorf("unsupported language: %s", module.Module.Lang)
-	}
+	// In offline mode, every prereq that can't be resolved locally is collected so they can all be reported at once.
+	// Once one is missing, the rest are only checked for, as later prereqs usually depend on earlier ones.
+	missing := []string{}
 
 	for _, p := range preReqs {
 
@@ -234,41 +703,151 @@ func (b *Builder) checkAndRunPreReqs(module project.ModuleDir, result *BuildResu
 
 		if _, err := os.Stat(filepathVar); err != nil {
 			if errors.Is(err, os.ErrNotExist) {
+				if len(missing) > 0 {
+					missing = append(missing, fmt.Sprintf("%s (not attempted, as an earlier prerequisite is missing)", p.File))
+					continue
+				}
+
 				b.log.LogStart(fmt.Sprintf("missing %s, fixing...", p.File))
 
+				if b.Config.Offline && (p.Mirror != "" || p.OfflineCommand != "") {
+					if reason, err := b.resolveOfflinePreReq(ctx, p, module, result); err != nil {
+						return errors.Wrap(err, "failed to resolveOfflinePreReq")
+					} else if reason != "" {
+						missing = append(missing, fmt.Sprintf("%s (%s)", p.File, reason))
+						continue
+					}
+
+					b.log.LogDone("fixed!")
+					continue
+				}
+
 				fullCmd, err := p.GetCommand(*b.Config, module)
 				if err != nil {
 					return errors.Wrap(err, "prereq.GetCommand")
 				}
 
-				outputLog, err := b.Config.CommandRunner.RunInDir(fullCmd, module.Fullpath)
-				if err != nil {
-					return errors.Wrapf(err, "commandRunner.RunInDir: %s", fullCmd)
+				if err := b.runCmd(ctx, util.Cmd{Shell: fullCmd, Dir: module.Fullpath}, result); err != nil {
+					return errors.Wrapf(err, "failed to runCmd: %s", fullCmd)
 				}
 
-				result.OutputLog += outputLog + "\n"
-
 				b.log.LogDone("fixed!")
 			}
 		}
 	}
 
+	if len(missing) > 0 {
+		return fmt.Errorf("offline build of %s is missing prerequisites:\n  - %s", module.Name, strings.Join(missing, "\n  - "))
+	}
+
 	return nil
 }
 
+// runCmd runs a command for a module, adding its output to the result even if it fails.
+func (b *Builder) runCmd(ctx context.Context, cmd util.Cmd, result *BuildResult) error {
+	cmdResult, err := b.Config.CommandRunner.Exec(ctx, cmd)
+	if cmdResult != nil {
+		result.OutputLog += cmdResult.Output + "\n"
+		result.ErrorLog += cmdResult.Stderr
+	}
+
+	return err
+}
+
+// builderContainerName returns a unique name for the container that builds a module.
+func builderContainerName(mod project.ModuleDir) string {
+	suffix := make([]byte, 4)
+	_, _ = rand.Read(suffix)
+
+	name := strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') || r == '-' || r == '_' {
+			return r
+		}
+
+		return '-'
+	}, mod.Name)
+
+	return fmt.Sprintf("subo-build-%s-%s", name, hex.EncodeToString(suffix))
+}
+
+// mirrorDir returns the configured offline mirror directory, or the default one in the user's cache dir.
+func (b *Builder) mirrorDir() string {
+	if b.Config.MirrorDir != "" {
+		return b.Config.MirrorDir
+	}
+
+	dir, err := util.CacheDir("subo", "mirror")
+	if err != nil {
+		return filepath.Join(os.TempDir(), "subo", "mirror")
+	}
+
+	return dir
+}
+
+// resolveOfflinePreReq acquires a prereq from the offline mirror or with its offline command.
+// If it can't be acquired, the reason is returned rather than an error.
+func (b *Builder) resolveOfflinePreReq(ctx context.Context, p Prereq, module project.ModuleDir, result *BuildResult) (string, error) {
+	if p.Mirror != "" {
+		mirrorPath, err := p.GetMirrorPath(*b.Config, module)
+		if err != nil {
+			return "", errors.Wrap(err, "prereq.GetMirrorPath")
+		}
+
+		source := filepath.Join(b.mirrorDir(), mirrorPath)
+
+		if _, err := os.Stat(source); err == nil {
+			if err := copyPath(source, filepath.Join(module.Fullpath, p.File)); err != nil {
+				return "", errors.Wrapf(err, "failed to copy %s from mirror", source)
+			}
+
+			return "", nil
+		}
+
+		if p.OfflineCommand == "" {
+			return fmt.Sprintf("not found in mirror at %s", source), nil
+		}
+	}
+
+	fullCmd, err := p.GetOfflineCommand(*b.Config, module)
+	if err != nil {
+		return "", errors.Wrap(err, "prereq.GetOfflineCommand")
+	}
+
+	err = b.runCmd(ctx, util.Cmd{Shell: fullCmd, Dir: module.Fullpath}, result)
+
+	if ctx.Err() != nil {
+		return "", errors.Wrap(err, "failed to runCmd")
+	} else if err != nil {
+		return fmt.Sprintf("`%s` failed: %s", fullCmd, err.Error()), nil
+	}
+
+	return "", nil
+}
+
 // analyzeForCompilerFlags looks at the module and determines if any additional compiler flags are needed
-// this is initially added to support AS-JSON in AssemblyScript with its need for the --transform flag.
+// according to its language's CompilerFlagRules, for example AS-JSON's need for the --transform flag in AssemblyScript.
 func (b *Builder) analyzeForCompilerFlags(md project.ModuleDir) (string, error) {
-	if md.Module.Lang == "assemblyscript" {
-		packageJSONBytes, err := ioutil.ReadFile(filepath.Join(md.Fullpath, "package.json"))
+	l, exists := project.LanguageFor(md.Module.Lang)
+	if !exists {
+		return "", fmt.Errorf("unsupported language: %s", md.Module.Lang)
+	}
+
+	flags := []string{}
+
+	for _, rule := range l.CompilerFlags {
+		fileBytes, err := ioutil.ReadFile(filepath.Join(md.Fullpath, rule.File))
 		if err != nil {
-			return "", errors.Wrap(err, "failed to ReadFile package.json")
+			if os.IsNotExist(err) {
+				continue
+			}
+
+			return "", errors.Wrapf(err, "failed to ReadFile %s", rule.File)
 		}
 
-		if strings.Contains(string(packageJSONBytes), "json-as") {
-			return "--transform ./node_modules/json-as/transform", nil
+		if strings.Contains(string(fileBytes), rule.Contains) {
+			flags = append(flags, rule.Flags)
 		}
 	}
 
-	return "", nil
+	return strings.Join(flags, " "), nil
 }
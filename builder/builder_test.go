@@ -0,0 +1,324 @@
+package builder
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/suborbital/subo/project"
+	"github.com/suborbital/subo/subo/util"
+	"github.com/suborbital/systemspec/tenant"
+)
+
+// testCommands decides the results of the commands recorded by a util.RecordingRunner, failing any command
+// run in a directory listed in failDirs or containing a string in failCmds. Commands containing a string in
+// blockCmds run until their context is cancelled.
+type testCommands struct {
+	failDirs  map[string]bool
+	failCmds  []string
+	blockCmds []string
+}
+
+func (c testCommands) runner() *util.RecordingRunner {
+	return &util.RecordingRunner{Handler: c.result}
+}
+
+func (c testCommands) result(ctx context.Context, cmd util.Cmd) (*util.CmdResult, error) {
+	cmdString := cmd.String()
+
+	for _, b := range c.blockCmds {
+		if strings.Contains(cmdString, b) {
+			<-ctx.Done()
+			return &util.CmdResult{Output: "output from " + cmdString, ExitCode: -1}, ctx.Err()
+		}
+	}
+
+	if c.failDirs[cmd.Dir] {
+		return &util.CmdResult{Output: "output from " + cmd.Dir, Stderr: "error from " + cmd.Dir, ExitCode: 1}, errors.New("command failed")
+	}
+
+	for _, f := range c.failCmds {
+		if strings.Contains(cmdString, f) {
+			return &util.CmdResult{Output: "output from " + cmdString, Stderr: "error from " + cmdString, ExitCode: 1}, errors.New("command failed")
+		}
+	}
+
+	return &util.CmdResult{Output: "output from " + cmd.Dir, Stdout: "output from " + cmd.Dir}, nil
+}
+
+// cmdDirs returns the directories that the runner's commands were run in.
+func cmdDirs(runner *util.RecordingRunner) []string {
+	dirs := []string{}
+	for _, cmd := range runner.Cmds() {
+		dirs = append(dirs, cmd.Dir)
+	}
+
+	return dirs
+}
+
+type silentLogger struct{}
+
+func (s *silentLogger) LogInfo(string)  {}
+func (s *silentLogger) LogStart(string) {}
+func (s *silentLogger) LogDone(string)  {}
+func (s *silentLogger) LogFail(string)  {}
+func (s *silentLogger) LogWarn(string)  {}
+
+var _ util.FriendlyLogger = &silentLogger{}
+
+func testBuilder(runner util.CommandRunner, jobs int, names ...string) *Builder {
+	mods := []project.ModuleDir{}
+	for _, n := range names {
+		mods = append(mods, project.ModuleDir{
+			Name:     n,
+			Fullpath: "/" + n,
+			Module:   &tenant.Module{Name: n, Lang: "wat"},
+		})
+	}
+
+	return &Builder{
+		Context: &project.Context{Modules: mods},
+		Config:  &BuildConfig{JsToolchain: "npm", CommandRunner: runner, Jobs: jobs},
+		log:     &silentLogger{},
+	}
+}
+
+func TestBuilder_BuildWithToolchain_Parallel(t *testing.T) {
+	failDirs := map[string]bool{"/two": true, "/four": true}
+	runner := testCommands{failDirs: failDirs}.runner()
+	b := testBuilder(runner, 3, "one", "two", "three", "four", "five")
+
+	err := b.BuildWithToolchain(context.Background(), ToolchainNative)
+	if assert.Error(t, err) {
+		assert.True(t, strings.Contains(err.Error(), "2 of 5"))
+		assert.True(t, strings.Contains(err.Error(), "two, four"))
+	}
+
+	// Every module should have been attempted despite the failures.
+	assert.Len(t, cmdDirs(runner), 5)
+
+	results, err := b.Results()
+	assert.NoError(t, err)
+
+	if assert.Len(t, results, 5) {
+		for i, name := range []string{"one", "two", "three", "four", "five"} {
+			assert.Equal(t, name, results[i].Name)
+			assert.Equal(t, "output from /"+name+"\n", results[i].OutputLog)
+			assert.Equal(t, !failDirs["/"+name], results[i].Succeeded)
+		}
+	}
+}
+
+func TestModuleBuildCommands(t *testing.T) {
+	langCmds, err := NativeBuildCommands("wat")
+	assert.NoError(t, err)
+
+	tests := []struct {
+		name   string
+		config project.ModuleConfig
+		want   []string
+	}{
+		{
+			name:   "uses the language commands by default",
+			config: project.ModuleConfig{},
+			want:   langCmds,
+		},
+		{
+			name: "adds preBuild and postBuild commands",
+			config: project.ModuleConfig{
+				PreBuild:  []string{"echo pre"},
+				PostBuild: []string{"wasm-opt -O {{ .Name }}.wasm -o {{ .Name }}.wasm"},
+			},
+			want: append(append([]string{"echo pre"}, langCmds...), "wasm-opt -O {{ .Name }}.wasm -o {{ .Name }}.wasm"),
+		},
+		{
+			name: "overrides the build commands",
+			config: project.ModuleConfig{
+				PreBuild: []string{"echo pre"},
+				Build:    []string{"custom-build"},
+			},
+			want: []string{"echo pre", "custom-build"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := moduleBuildCommands(project.ModuleDir{
+				Module: &tenant.Module{Lang: "wat"},
+				Config: tt.config,
+			})
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestBuilder_BuildWithToolchain_Docker(t *testing.T) {
+	runner := testCommands{failCmds: []string{"build two --native"}}.runner()
+	b := testBuilder(runner, 2, "one", "two", "three")
+
+	b.Context.Cwd = "/"
+	b.Context.MountPath = "/"
+	b.Context.RelDockerPath = "."
+	b.Context.BuilderTag = "v0.6.0"
+	b.Config.ContainerEngine = &util.ContainerEngine{Name: "docker", Rootless: true}
+
+	err := b.BuildWithToolchain(context.Background(), ToolchainDocker)
+	if assert.Error(t, err) {
+		assert.True(t, strings.Contains(err.Error(), "1 of 3"))
+	}
+
+	// Each module should get its own (uniquely named) container.
+	cmds := []string{}
+	for _, cmd := range runner.Strings() {
+		cmds = append(cmds, containerNameRegex.ReplaceAllString(cmd, "--name subo-build-$1"))
+	}
+
+	assert.ElementsMatch(t, []string{
+		"docker run --rm --name subo-build-one --mount type=bind,source=/,target=/root/module suborbital/builder-wat:v0.6.0 subo build one --native",
+		"docker run --rm --name subo-build-two --mount type=bind,source=/,target=/root/module suborbital/builder-wat:v0.6.0 subo build two --native",
+		"docker run --rm --name subo-build-three --mount type=bind,source=/,target=/root/module suborbital/builder-wat:v0.6.0 subo build three --native",
+	}, cmds)
+
+	results, err := b.Results()
+	assert.NoError(t, err)
+
+	if assert.Len(t, results, 3) {
+		assert.True(t, results[0].Succeeded)
+		assert.False(t, results[1].Succeeded)
+		assert.True(t, results[2].Succeeded)
+		assert.Equal(t, ToolchainDocker, results[1].Toolchain)
+		assert.True(t, strings.Contains(results[1].OutputLog, "subo build two"))
+	}
+}
+
+func TestBuilder_BuildWithToolchain_DockerRunArgs(t *testing.T) {
+	runner := testCommands{}.runner()
+	b := testBuilder(runner, 1, "game")
+
+	b.Context.Modules[0].Module.Lang = "grain"
+	b.Context.Cwd = "/"
+	b.Context.MountPath = "/"
+	b.Context.RelDockerPath = "."
+	b.Context.BuilderTag = "v0.6.0"
+	b.Config.ContainerEngine = &util.ContainerEngine{Name: "docker", Rootless: true}
+
+	require.NoError(t, b.BuildWithToolchain(context.Background(), ToolchainDocker))
+
+	cmds := runner.Cmds()
+	require.Len(t, cmds, 1)
+
+	// The platform and image must be separate arguments rather than a single image name.
+	args := cmds[0].Args
+	image := indexOf(args, "suborbital/builder-gr:v0.6.0")
+	require.NotEqual(t, -1, image)
+	assert.Equal(t, []string{"--platform", "linux/amd64"}, args[image-2:image])
+}
+
+var containerNameRegex = regexp.MustCompile(`--name subo-build-(\w+)-[0-9a-f]{8}`)
+
+func TestBuilder_BuildWithToolchain_Timeout(t *testing.T) {
+	runner := testCommands{blockCmds: []string{"build two --native"}}.runner()
+	b := testBuilder(runner, 3, "one", "two", "three")
+
+	b.Context.Cwd = "/"
+	b.Context.MountPath = "/"
+	b.Context.RelDockerPath = "."
+	b.Config.Timeout = 50 * time.Millisecond
+	b.Config.ContainerEngine = &util.ContainerEngine{Name: "docker", Rootless: true}
+
+	err := b.BuildWithToolchain(context.Background(), ToolchainDocker)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "1 of 3")
+	}
+
+	// The container that timed out should be removed.
+	removed := []string{}
+	for _, cmd := range runner.Strings() {
+		if strings.HasPrefix(cmd, "docker rm -f ") {
+			removed = append(removed, cmd)
+		}
+	}
+
+	if assert.Len(t, removed, 1) {
+		assert.Regexp(t, `^docker rm -f subo-build-two-[0-9a-f]{8}$`, removed[0])
+	}
+
+	results, err := b.Results()
+	assert.NoError(t, err)
+
+	if assert.Len(t, results, 3) {
+		assert.True(t, results[0].Succeeded)
+		assert.False(t, results[1].Succeeded)
+		assert.Contains(t, results[1].Error, "timed out after 50ms")
+		assert.True(t, results[2].Succeeded)
+	}
+}
+
+func TestBuilder_BuildWithToolchain_Cancelled(t *testing.T) {
+	runner := testCommands{}.runner()
+	b := testBuilder(runner, 1, "one", "two")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := b.BuildWithToolchain(ctx, ToolchainNative)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "2 of 2")
+	}
+
+	assert.Empty(t, runner.Strings(), "no commands should run once the build is cancelled")
+}
+
+func TestBuilder_BuildWithToolchain_Dependencies(t *testing.T) {
+	runner := testCommands{failDirs: map[string]bool{"/broken": true}}.runner()
+	b := testBuilder(runner, 3, "app", "lib", "utils", "broken", "consumer")
+
+	b.Context.Modules[0].Config.DependsOn = []string{"lib"}
+	b.Context.Modules[1].Config.DependsOn = []string{"utils"}
+	b.Context.Modules[4].Config.DependsOn = []string{"broken"}
+
+	err := b.BuildWithToolchain(context.Background(), ToolchainNative)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "2 of 5")
+	}
+
+	// Dependencies must finish building before their dependents start.
+	assert.Less(t, indexOf(cmdDirs(runner), "/utils"), indexOf(cmdDirs(runner), "/lib"))
+	assert.Less(t, indexOf(cmdDirs(runner), "/lib"), indexOf(cmdDirs(runner), "/app"))
+
+	// A module whose dependency failed isn't built at all.
+	assert.Equal(t, -1, indexOf(cmdDirs(runner), "/consumer"))
+
+	results, err := b.Results()
+	assert.NoError(t, err)
+
+	succeeded := map[string]bool{}
+	errs := map[string]string{}
+	for _, r := range results {
+		succeeded[r.Name] = r.Succeeded
+		errs[r.Name] = r.Error
+	}
+
+	assert.Equal(t, map[string]bool{"utils": true, "lib": true, "app": true, "broken": false, "consumer": false}, succeeded)
+	assert.Equal(t, "dependency broken failed to build", errs["consumer"])
+	assert.NotEmpty(t, errs["broken"])
+	assert.Empty(t, errs["app"])
+}
+
+func indexOf(list []string, val string) int {
+	for i, v := range list {
+		if v == val {
+			return i
+		}
+	}
+
+	return -1
+}
@@ -0,0 +1,242 @@
+package builder
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/suborbital/subo/project"
+	"github.com/suborbital/subo/subo/util"
+)
+
+// buildOutputDirs are directories created by the language toolchains which must not affect a module's source
+// hash. Directories that only some languages create are listed in their OutputDirs.
+var buildOutputDirs = map[string]bool{
+	"target":       true,
+	".build":       true,
+	"node_modules": true,
+	"_lib":         true,
+	".git":         true,
+	".subo":        true, // build matrix results.
+}
+
+// IsBuildOutput returns true if a file or directory with the given name is produced by building a module in
+// the given language (or is otherwise irrelevant to its sources) and should not be treated as a source file.
+func IsBuildOutput(lang, name string, isDir bool) bool {
+	if isDir {
+		if buildOutputDirs[name] {
+			return true
+		}
+
+		if l, exists := project.LanguageFor(lang); exists {
+			for _, dir := range l.OutputDirs {
+				if dir == name {
+					return true
+				}
+			}
+		}
+
+		return false
+	}
+
+	return strings.HasSuffix(name, ".wasm") || strings.HasSuffix(name, ".wasm.zip")
+}
+
+// BuildCache stores built Wasm modules keyed on the hash of everything that went into building them.
+type BuildCache struct {
+	dir string
+}
+
+// NewBuildCache returns a BuildCache stored in subo's cache directory.
+func NewBuildCache() (*BuildCache, error) {
+	dir, err := util.CacheDir("subo", "builds")
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to CacheDir")
+	}
+
+	return &BuildCache{dir: dir}, nil
+}
+
+// Key returns the cache key for a module, derived from its source files (including .module.yaml and
+// any shared directories), its language, the toolchain and the builder tag. Options are any other build settings
+// that affect the output, such as the versions of the native toolchain's tools.
+func (c *BuildCache) Key(mod project.ModuleDir, tcn Toolchain, builderTag string, options ...string) (string, error) {
+	hash := sha256.New()
+
+	fmt.Fprintf(hash, "lang:%s\ntoolchain:%s\ntag:%s\n", mod.Module.Lang, tcn, builderTag)
+
+	for _, o := range options {
+		fmt.Fprintf(hash, "option:%s\n", o)
+	}
+
+	if err := hashSourceDir(hash, mod.Module.Lang, mod.Fullpath, "file"); err != nil {
+		return "", errors.Wrap(err, "failed to hashSourceDir")
+	}
+
+	for i, shared := range mod.SharedDirPaths() {
+		if err := hashSourceDir(hash, mod.Module.Lang, shared, fmt.Sprintf("shared%d", i)); err != nil {
+			return "", errors.Wrapf(err, "failed to hashSourceDir for shared dir %s", shared)
+		}
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// hashSourceDir writes the path (relative to dir) and contents of every source file in dir to hash.
+func hashSourceDir(hash io.Writer, lang, dir, prefix string) error {
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if path != dir && IsBuildOutput(lang, d.Name(), d.IsDir()) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+
+			return nil
+		}
+
+		if d.IsDir() || !d.Type().IsRegular() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return errors.Wrap(err, "failed to Rel")
+		}
+
+		file, err := os.Open(path)
+		if err != nil {
+			return errors.Wrapf(err, "failed to Open %s", path)
+		}
+
+		defer file.Close()
+
+		fmt.Fprintf(hash, "%s:%s\n", prefix, filepath.ToSlash(rel))
+
+		if _, err := io.Copy(hash, file); err != nil {
+			return errors.Wrapf(err, "failed to hash %s", path)
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return errors.Wrap(err, "failed to WalkDir")
+	}
+
+	return nil
+}
+
+// Restore copies the cached Wasm file for key into the module directory, returning false if there is no cache entry.
+func (c *BuildCache) Restore(key string, mod project.ModuleDir) (bool, error) {
+	cachedPath := c.entryPath(key)
+
+	if _, err := os.Stat(cachedPath); err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+
+		return false, errors.Wrap(err, "failed to Stat cache entry")
+	}
+
+	if err := copyFile(cachedPath, wasmPath(mod)); err != nil {
+		return false, errors.Wrap(err, "failed to copyFile from cache")
+	}
+
+	return true, nil
+}
+
+// Store copies the module's built Wasm file into the cache under key.
+func (c *BuildCache) Store(key string, mod project.ModuleDir) error {
+	// Copy to a temporary file first so that a partially written entry is never restored. Each store has its
+	// own temporary file, as modules with the same key can be stored at the same time (e.g. by parallel builds).
+	tmpFile, err := ioutil.TempFile(c.dir, fmt.Sprintf("%s-*.tmp", key))
+	if err != nil {
+		return errors.Wrap(err, "failed to TempFile")
+	}
+
+	tmpPath := tmpFile.Name()
+	tmpFile.Close()
+
+	if err := copyFile(wasmPath(mod), tmpPath); err != nil {
+		os.Remove(tmpPath)
+		return errors.Wrap(err, "failed to copyFile to cache")
+	}
+
+	if err := os.Rename(tmpPath, c.entryPath(key)); err != nil {
+		os.Remove(tmpPath)
+		return errors.Wrap(err, "failed to Rename cache entry")
+	}
+
+	return nil
+}
+
+func (c *BuildCache) entryPath(key string) string {
+	return filepath.Join(c.dir, fmt.Sprintf("%s.wasm", key))
+}
+
+func wasmPath(mod project.ModuleDir) string {
+	return filepath.Join(mod.Fullpath, fmt.Sprintf("%s.wasm", mod.Name))
+}
+
+// copyPath copies a file or directory (recursively) from src to dst.
+func copyPath(src, dst string) error {
+	info, err := os.Stat(src)
+	if err != nil {
+		return errors.Wrap(err, "failed to Stat")
+	}
+
+	if !info.IsDir() {
+		return copyFile(src, dst)
+	}
+
+	return filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return errors.Wrap(err, "failed to Rel")
+		}
+
+		target := filepath.Join(dst, rel)
+
+		if d.IsDir() {
+			return os.MkdirAll(target, util.PermDirectory)
+		}
+
+		return copyFile(path, target)
+	})
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return errors.Wrapf(err, "failed to Open %s", src)
+	}
+
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, util.PermFile)
+	if err != nil {
+		return errors.Wrapf(err, "failed to OpenFile %s", dst)
+	}
+
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return errors.Wrapf(err, "failed to Copy to %s", dst)
+	}
+
+	return out.Close()
+}
@@ -0,0 +1,195 @@
+package builder
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/suborbital/subo/project"
+	"github.com/suborbital/systemspec/tenant"
+)
+
+func TestBuildCache(t *testing.T) {
+	modPath := t.TempDir()
+	cache := &BuildCache{dir: t.TempDir()}
+
+	mod := project.ModuleDir{
+		Name:     "hello",
+		Fullpath: modPath,
+		Module:   &tenant.Module{Name: "hello", Lang: "tinygo"},
+	}
+
+	require.NoError(t, os.WriteFile(filepath.Join(modPath, "main.go"), []byte("package main"), 0644))
+
+	key, err := cache.Key(mod, ToolchainNative, "v1")
+	require.NoError(t, err)
+
+	hit, err := cache.Restore(key, mod)
+	require.NoError(t, err)
+	assert.False(t, hit)
+
+	// Build outputs must not change the key.
+	require.NoError(t, os.WriteFile(filepath.Join(modPath, "hello.wasm"), []byte("wasm"), 0644))
+	require.NoError(t, os.MkdirAll(filepath.Join(modPath, "target"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(modPath, "target", "out"), []byte("out"), 0644))
+
+	sameKey, err := cache.Key(mod, ToolchainNative, "v1")
+	require.NoError(t, err)
+	assert.Equal(t, key, sameKey)
+
+	require.NoError(t, cache.Store(key, mod))
+	require.NoError(t, os.Remove(filepath.Join(modPath, "hello.wasm")))
+
+	hit, err = cache.Restore(key, mod)
+	require.NoError(t, err)
+	assert.True(t, hit)
+
+	wasm, err := os.ReadFile(filepath.Join(modPath, "hello.wasm"))
+	require.NoError(t, err)
+	assert.Equal(t, "wasm", string(wasm))
+
+	tagKey, err := cache.Key(mod, ToolchainNative, "v2")
+	require.NoError(t, err)
+	assert.NotEqual(t, key, tagKey)
+
+	require.NoError(t, os.WriteFile(filepath.Join(modPath, "main.go"), []byte("package main\n"), 0644))
+
+	changedKey, err := cache.Key(mod, ToolchainNative, "v1")
+	require.NoError(t, err)
+	assert.NotEqual(t, key, changedKey)
+}
+
+func TestBuildCache_Key_SharedDirs(t *testing.T) {
+	projectPath := t.TempDir()
+	cache := &BuildCache{dir: t.TempDir()}
+
+	modPath := filepath.Join(projectPath, "hello")
+	sharedPath := filepath.Join(projectPath, "shared")
+
+	require.NoError(t, os.MkdirAll(modPath, 0755))
+	require.NoError(t, os.MkdirAll(sharedPath, 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(modPath, "index.ts"), []byte("import '../shared'"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(sharedPath, "lib.ts"), []byte("export {}"), 0644))
+
+	mod := project.ModuleDir{
+		Name:     "hello",
+		Fullpath: modPath,
+		Module:   &tenant.Module{Name: "hello", Lang: "assemblyscript"},
+		Config:   project.ModuleConfig{SharedDirs: []string{"../shared"}},
+	}
+
+	key, err := cache.Key(mod, ToolchainNative, "v1")
+	require.NoError(t, err)
+
+	require.NoError(t, os.WriteFile(filepath.Join(sharedPath, "lib.ts"), []byte("export const x = 1"), 0644))
+
+	changedKey, err := cache.Key(mod, ToolchainNative, "v1")
+	require.NoError(t, err)
+	assert.NotEqual(t, key, changedKey, "changes to shared dirs must change the key")
+}
+
+func TestIsBuildOutput(t *testing.T) {
+	assert.True(t, IsBuildOutput("rust", "vendor", true))
+	assert.False(t, IsBuildOutput("tinygo", "vendor", true), "Go modules can vendor their dependencies as sources")
+	assert.True(t, IsBuildOutput("tinygo", "node_modules", true))
+	assert.True(t, IsBuildOutput("tinygo", "hello.wasm", false))
+	assert.False(t, IsBuildOutput("rust", "vendor", false))
+}
+
+func TestBuildCache_Store_Concurrent(t *testing.T) {
+	cache := &BuildCache{dir: t.TempDir()}
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < 8; i++ {
+		mod := project.ModuleDir{Name: "hello", Fullpath: t.TempDir()}
+		require.NoError(t, os.WriteFile(wasmPath(mod), []byte("wasm"), 0644))
+
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, cache.Store("key", mod))
+		}()
+	}
+
+	wg.Wait()
+
+	entries, err := os.ReadDir(cache.dir)
+	require.NoError(t, err)
+	require.Len(t, entries, 1, "temporary files must not be left behind")
+	assert.Equal(t, "key.wasm", entries[0].Name())
+}
+
+func TestBuilder_NativeToolVersions(t *testing.T) {
+	require.NoError(t, project.RegisterLanguage(project.Language{
+		Name:            "test-versioned",
+		VersionCommands: []string{"echo tool 1.0", "echo {{ .BuildConfig.JsToolchain }} 2.0"},
+	}))
+
+	b := testBuilder(testCommands{}.runner(), 1)
+	b.Config.JsToolchain = "yarn"
+
+	versions, err := b.nativeToolVersions("test-versioned")
+	require.NoError(t, err)
+	assert.Equal(t, "tool 1.0\nyarn 2.0\n", versions)
+
+	// The versions are only gathered once.
+	require.NoError(t, project.RegisterLanguage(project.Language{Name: "test-versioned", VersionCommands: []string{"echo tool 1.1"}}))
+
+	versions, err = b.nativeToolVersions("test-versioned")
+	require.NoError(t, err)
+	assert.Equal(t, "tool 1.0\nyarn 2.0\n", versions)
+
+	b = testBuilder(testCommands{}.runner(), 1)
+
+	versions, err = b.nativeToolVersions("test-versioned")
+	require.NoError(t, err)
+	assert.Equal(t, "tool 1.1\n", versions)
+}
+
+func TestBuilder_CacheKey_Dependencies(t *testing.T) {
+	module := func(namespace, name string, dependsOn ...string) project.ModuleDir {
+		dir := t.TempDir()
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "main.wat"), []byte("(module)"), 0644))
+
+		return project.ModuleDir{
+			Name:     name,
+			Fullpath: dir,
+			Module:   &tenant.Module{Name: name, Namespace: namespace, Lang: "wat"},
+			Config:   project.ModuleConfig{DependsOn: dependsOn},
+		}
+	}
+
+	// The other namespace's lib comes first and depends on app rather than the other way around,
+	// so looking dependencies up by name alone would use the wrong module (and never finish).
+	otherLib := module("other", "lib", "app")
+	otherApp := module("other", "app")
+	lib := module("default", "lib")
+	app := module("default", "app", "lib")
+
+	b := testBuilder(testCommands{}.runner(), 1)
+	b.Context.Modules = []project.ModuleDir{otherLib, app, lib, otherApp}
+	b.cache = &BuildCache{dir: t.TempDir()}
+	// The version commands aren't run, as the tools may not be installed.
+	b.toolVersions = map[string]string{"wat": "wat2wasm 1.0\n"}
+
+	key, err := b.cacheKey(app)
+	require.NoError(t, err)
+
+	require.NoError(t, os.WriteFile(filepath.Join(otherLib.Fullpath, "main.wat"), []byte("(module $other)"), 0644))
+
+	otherChangedKey, err := b.cacheKey(app)
+	require.NoError(t, err)
+	assert.Equal(t, key, otherChangedKey)
+
+	require.NoError(t, os.WriteFile(filepath.Join(lib.Fullpath, "main.wat"), []byte("(module $lib)"), 0644))
+
+	libChangedKey, err := b.cacheKey(app)
+	require.NoError(t, err)
+	assert.NotEqual(t, key, libChangedKey)
+}
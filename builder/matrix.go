@@ -0,0 +1,257 @@
+package builder
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/pkg/errors"
+
+	"github.com/suborbital/subo/project"
+	"github.com/suborbital/subo/subo/util"
+)
+
+// MatrixEntry is one of the configurations that modules are built with by a build matrix.
+// Empty fields mean the project's own builder tag or the modules' own API versions are used.
+type MatrixEntry struct {
+	BuilderTag string
+	APIVersion string
+}
+
+// ParseMatrixEntry parses a matrix entry in the form tag=<builder tag> or api=<API version>.
+// A value without a key is treated as a builder tag.
+func ParseMatrixEntry(entry string) (MatrixEntry, error) {
+	key, val, hasKey := strings.Cut(entry, "=")
+	if !hasKey {
+		key, val = "tag", entry
+	}
+
+	if val == "" {
+		return MatrixEntry{}, fmt.Errorf("matrix entry %q is missing a value", entry)
+	}
+
+	switch key {
+	case "tag":
+		return MatrixEntry{BuilderTag: val}, nil
+	case "api":
+		return MatrixEntry{APIVersion: val}, nil
+	}
+
+	return MatrixEntry{}, fmt.Errorf("matrix entry %q must be in the form tag=<builder tag> or api=<API version>", entry)
+}
+
+// String returns the entry in the form accepted by ParseMatrixEntry.
+func (m MatrixEntry) String() string {
+	if m.APIVersion != "" {
+		return "api=" + m.APIVersion
+	}
+
+	return "tag=" + m.BuilderTag
+}
+
+// MatrixResult is the result of building modules with one entry of a build matrix.
+type MatrixResult struct {
+	Entry   MatrixEntry
+	Results []BuildResult
+	// OutputDir is where the modules built with this entry are stored.
+	OutputDir string
+}
+
+// MatrixDir returns the directory that the modules built by a build matrix are stored in.
+func (b *Builder) MatrixDir() string {
+	return filepath.Join(b.Context.Cwd, ".subo", "matrix")
+}
+
+// BuildMatrix builds the given modules once for each of the matrix entries. Each entry's
+// modules are stored separately within MatrixDir, and the modules' own .wasm files are restored
+// once the matrix is complete. Failed builds are recorded in the results rather than returned as errors.
+func (b *Builder) BuildMatrix(ctx context.Context, tcn Toolchain, mods []project.ModuleDir, entries []MatrixEntry) ([]MatrixResult, error) {
+	for _, e := range entries {
+		if e.BuilderTag != "" && tcn != ToolchainDocker {
+			// Native builds use the locally installed toolchains whatever the builder tag is.
+			return nil, fmt.Errorf("matrix entry %s requires the Docker toolchain, as builder tags only select builder images", e)
+		}
+	}
+
+	originals, err := backupWasmFiles(mods)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to backupWasmFiles")
+	}
+
+	defer func() {
+		if err := restoreWasmFiles(mods, originals); err != nil {
+			b.log.LogWarn(fmt.Sprintf("failed to restore modules after build matrix: %s", err.Error()))
+		}
+	}()
+
+	projectTag, configAPIVersion := b.Context.BuilderTag, b.Config.APIVersion
+	defer func() {
+		b.Context.BuilderTag, b.Config.APIVersion = projectTag, configAPIVersion
+	}()
+
+	matrix := []MatrixResult{}
+
+	for _, e := range entries {
+		if ctx.Err() != nil {
+			return matrix, errors.Wrap(ctx.Err(), "build matrix was cancelled")
+		}
+
+		b.log.LogInfo(fmt.Sprintf("building %d modules with %s", len(mods), e))
+
+		b.Context.BuilderTag, b.Config.APIVersion = projectTag, configAPIVersion
+		if e.BuilderTag != "" {
+			b.Context.BuilderTag = e.BuilderTag
+		}
+
+		if e.APIVersion != "" {
+			b.Config.APIVersion = e.APIVersion
+		}
+
+		// Build failures are expected in a matrix and are recorded in the results.
+		_ = b.BuildModulesWithToolchain(ctx, tcn, mods)
+
+		results := append([]BuildResult{}, b.results...)
+		outputDir := filepath.Join(b.MatrixDir(), matrixDirName(e))
+
+		if err := storeMatrixResults(results, outputDir); err != nil {
+			return matrix, errors.Wrapf(err, "failed to store results for %s", e)
+		}
+
+		matrix = append(matrix, MatrixResult{Entry: e, Results: results, OutputDir: outputDir})
+	}
+
+	return matrix, nil
+}
+
+// storeMatrixResults copies each successfully built module into its namespace's directory within outputDir,
+// pointing its result at the copy.
+func storeMatrixResults(results []BuildResult, outputDir string) error {
+	if err := os.RemoveAll(outputDir); err != nil {
+		return errors.Wrap(err, "failed to RemoveAll")
+	}
+
+	if err := os.MkdirAll(outputDir, util.PermDirectory); err != nil {
+		return errors.Wrap(err, "failed to MkdirAll")
+	}
+
+	for i := range results {
+		if !results[i].Succeeded || results[i].WasmPath == "" {
+			continue
+		}
+
+		// Modules are stored by namespace, as modules in different namespaces can have the same name.
+		stored := filepath.Join(outputDir, results[i].Namespace, fmt.Sprintf("%s.wasm", results[i].Name))
+
+		if err := os.MkdirAll(filepath.Dir(stored), util.PermDirectory); err != nil {
+			return errors.Wrap(err, "failed to MkdirAll")
+		}
+
+		if err := copyFile(results[i].WasmPath, stored); err != nil {
+			return errors.Wrapf(err, "failed to copy %s", results[i].Name)
+		}
+
+		results[i].WasmPath = stored
+	}
+
+	return nil
+}
+
+// matrixDirName returns a directory name for a matrix entry.
+func matrixDirName(e MatrixEntry) string {
+	return strings.NewReplacer("=", "-", "/", "_", ":", "_").Replace(e.String())
+}
+
+// backupWasmFiles reads each module's existing .wasm file, if it has one.
+func backupWasmFiles(mods []project.ModuleDir) (map[string][]byte, error) {
+	originals := map[string][]byte{}
+
+	for _, mod := range mods {
+		wasmBytes, err := ioutil.ReadFile(wasmPath(mod))
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+
+			return nil, errors.Wrapf(err, "failed to ReadFile for %s", mod.Name)
+		}
+
+		originals[mod.Key()] = wasmBytes
+	}
+
+	return originals, nil
+}
+
+// restoreWasmFiles puts back the modules' .wasm files, removing any that didn't exist before.
+func restoreWasmFiles(mods []project.ModuleDir, originals map[string][]byte) error {
+	for _, mod := range mods {
+		original, existed := originals[mod.Key()]
+		if !existed {
+			if err := os.Remove(wasmPath(mod)); err != nil && !errors.Is(err, os.ErrNotExist) {
+				return errors.Wrapf(err, "failed to Remove %s", wasmPath(mod))
+			}
+
+			continue
+		}
+
+		if err := ioutil.WriteFile(wasmPath(mod), original, util.PermFile); err != nil {
+			return errors.Wrapf(err, "failed to WriteFile %s", wasmPath(mod))
+		}
+	}
+
+	return nil
+}
+
+// WriteMatrixTable writes a table of which modules built successfully with each matrix entry.
+func WriteMatrixTable(w io.Writer, matrix []MatrixResult) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 3, ' ', 0)
+
+	header := []string{"MODULE"}
+	for _, m := range matrix {
+		header = append(header, m.Entry.String())
+	}
+
+	fmt.Fprintln(tw, strings.Join(header, "\t"))
+
+	if len(matrix) > 0 {
+		for i, r := range matrix[0].Results {
+			row := []string{r.Name}
+
+			for _, m := range matrix {
+				status := "FAIL"
+				if i < len(m.Results) && m.Results[i].Succeeded {
+					status = "pass"
+				}
+
+				row = append(row, status)
+			}
+
+			fmt.Fprintln(tw, strings.Join(row, "\t"))
+		}
+	}
+
+	if err := tw.Flush(); err != nil {
+		return errors.Wrap(err, "failed to Flush")
+	}
+
+	return nil
+}
+
+// MatrixFailures returns the number of failed builds across all of the matrix entries.
+func MatrixFailures(matrix []MatrixResult) int {
+	failures := 0
+
+	for _, m := range matrix {
+		for _, r := range m.Results {
+			if !r.Succeeded {
+				failures++
+			}
+		}
+	}
+
+	return failures
+}
@@ -0,0 +1,185 @@
+package builder
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/suborbital/subo/subo/util"
+)
+
+func TestParseMatrixEntry(t *testing.T) {
+	tests := []struct {
+		entry   string
+		want    MatrixEntry
+		wantErr bool
+	}{
+		{entry: "tag=v0.6.0", want: MatrixEntry{BuilderTag: "v0.6.0"}},
+		{entry: "v0.6.0", want: MatrixEntry{BuilderTag: "v0.6.0"}},
+		{entry: "api=0.16.0", want: MatrixEntry{APIVersion: "0.16.0"}},
+		{entry: "tag=", wantErr: true},
+		{entry: "api=", wantErr: true},
+		{entry: "sdk=0.16.0", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.entry, func(t *testing.T) {
+			got, err := ParseMatrixEntry(tt.entry)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestBuilder_BuildMatrix(t *testing.T) {
+	runner := testCommands{failCmds: []string{"v0.6.0 subo build two --native"}}.runner()
+	b := testBuilder(runner, 2, "one", "two")
+	b.Context.Cwd = t.TempDir()
+	b.Context.MountPath = b.Context.Cwd
+	b.Context.RelDockerPath = "."
+	b.Context.BuilderTag = "v0.5.0"
+	b.Config.ContainerEngine = &util.ContainerEngine{Name: "docker", Rootless: true}
+
+	for i := range b.Context.Modules {
+		b.Context.Modules[i].Fullpath = filepath.Join(b.Context.Cwd, b.Context.Modules[i].Name)
+		require.NoError(t, os.MkdirAll(b.Context.Modules[i].Fullpath, 0755))
+	}
+
+	// one has been built before, two hasn't, and two fails to build with v0.6.0.
+	onePath := filepath.Join(b.Context.Modules[0].Fullpath, "one.wasm")
+	require.NoError(t, os.WriteFile(onePath, []byte("original"), 0644))
+
+	entries := []MatrixEntry{{BuilderTag: "v0.5.0"}, {BuilderTag: "v0.6.0"}}
+
+	matrix, err := b.BuildMatrix(context.Background(), ToolchainDocker, b.Context.Modules, entries)
+	require.NoError(t, err)
+	require.Len(t, matrix, 2)
+
+	assert.Equal(t, 1, MatrixFailures(matrix))
+	assert.Equal(t, "v0.5.0", b.Context.BuilderTag, "the project's builder tag should be restored")
+
+	// Each entry builds with its own builder images.
+	images := []string{}
+	for _, cmd := range runner.Strings() {
+		for _, field := range strings.Fields(cmd) {
+			if strings.HasPrefix(field, "suborbital/builder-wat:") {
+				images = append(images, field)
+			}
+		}
+	}
+
+	assert.ElementsMatch(t, []string{"suborbital/builder-wat:v0.5.0", "suborbital/builder-wat:v0.5.0", "suborbital/builder-wat:v0.6.0", "suborbital/builder-wat:v0.6.0"}, images)
+
+	for _, m := range matrix {
+		if assert.Len(t, m.Results, 2) {
+			assert.True(t, m.Results[0].Succeeded)
+			assert.Equal(t, filepath.Join(m.OutputDir, "one.wasm"), m.Results[0].WasmPath)
+			assert.FileExists(t, m.Results[0].WasmPath)
+		}
+	}
+
+	assert.True(t, matrix[0].Results[1].Succeeded)
+	assert.False(t, matrix[1].Results[1].Succeeded)
+	assert.Equal(t, filepath.Join(b.MatrixDir(), "tag-v0.5.0"), matrix[0].OutputDir)
+	assert.Equal(t, filepath.Join(b.MatrixDir(), "tag-v0.6.0"), matrix[1].OutputDir)
+
+	// The modules' own files are left as they were.
+	original, err := os.ReadFile(onePath)
+	assert.NoError(t, err)
+	assert.Equal(t, "original", string(original))
+
+	buf := &bytes.Buffer{}
+	require.NoError(t, WriteMatrixTable(buf, matrix))
+	assert.Equal(t, "MODULE   tag=v0.5.0   tag=v0.6.0\none      pass         pass\ntwo      pass         FAIL\n", buf.String())
+}
+
+func TestStoreMatrixResults(t *testing.T) {
+	dir := t.TempDir()
+
+	results := []BuildResult{
+		{Name: "hello", Namespace: "default", Succeeded: true, WasmPath: filepath.Join(dir, "default.wasm")},
+		{Name: "hello", Namespace: "other", Succeeded: true, WasmPath: filepath.Join(dir, "other.wasm")},
+		{Name: "broken", Namespace: "default"},
+	}
+
+	for _, r := range results[:2] {
+		require.NoError(t, os.WriteFile(r.WasmPath, []byte(r.Namespace), 0644))
+	}
+
+	outputDir := filepath.Join(dir, "tag-v0.6.0")
+	require.NoError(t, storeMatrixResults(results, outputDir))
+
+	for _, r := range results[:2] {
+		assert.Equal(t, filepath.Join(outputDir, r.Namespace, "hello.wasm"), r.WasmPath)
+
+		stored, err := os.ReadFile(r.WasmPath)
+		require.NoError(t, err)
+		assert.Equal(t, r.Namespace, string(stored))
+	}
+
+	assert.Empty(t, results[2].WasmPath)
+}
+
+func TestBuilder_BuildMatrix_APIVersions(t *testing.T) {
+	runner := testCommands{failCmds: []string{"--api-version 0.16.0"}}.runner()
+	b := testBuilder(runner, 1, "one")
+	b.Context.Cwd = t.TempDir()
+	b.Context.MountPath = b.Context.Cwd
+	b.Context.RelDockerPath = "."
+	b.Context.BuilderTag = "v0.5.0"
+	b.Context.Modules[0].Fullpath = filepath.Join(b.Context.Cwd, "one")
+	b.Context.Modules[0].Module.APIVersion = "0.15.1"
+	b.Config.ContainerEngine = &util.ContainerEngine{Name: "docker", Rootless: true}
+
+	entries := []MatrixEntry{{BuilderTag: "v0.6.0"}, {APIVersion: "0.16.0"}}
+
+	matrix, err := b.BuildMatrix(context.Background(), ToolchainDocker, b.Context.Modules, entries)
+	require.NoError(t, err)
+	require.Len(t, matrix, 2)
+
+	// The tag entry builds with the modules' own API versions, and the API version entry with the project's builder tag.
+	cmds := runner.Strings()
+	require.Len(t, cmds, 2)
+	assert.Contains(t, cmds[0], "suborbital/builder-wat:v0.6.0 subo build one --native")
+	assert.NotContains(t, cmds[0], "--api-version")
+	assert.Contains(t, cmds[1], "suborbital/builder-wat:v0.5.0 subo build one --native --api-version 0.16.0")
+
+	assert.True(t, matrix[0].Results[0].Succeeded)
+	assert.False(t, matrix[1].Results[0].Succeeded)
+	assert.Equal(t, filepath.Join(b.MatrixDir(), "api-0.16.0"), matrix[1].OutputDir)
+
+	assert.Equal(t, "0.15.1", b.Context.Modules[0].Module.APIVersion, "API versions must not leak into the context")
+	assert.Empty(t, b.Config.APIVersion, "the build config's API version should be restored")
+
+	buf := &bytes.Buffer{}
+	require.NoError(t, WriteMatrixTable(buf, matrix))
+	assert.Equal(t, "MODULE   tag=v0.6.0   api=0.16.0\none      pass         FAIL\n", buf.String())
+}
+
+func TestBuilder_BuildMatrix_Native(t *testing.T) {
+	runner := testCommands{}.runner()
+	b := testBuilder(runner, 1, "one")
+	b.Context.Cwd = t.TempDir()
+	b.Context.Modules[0].Fullpath = filepath.Join(b.Context.Cwd, "one")
+
+	_, err := b.BuildMatrix(context.Background(), ToolchainNative, b.Context.Modules, []MatrixEntry{{APIVersion: "0.16.0"}, {BuilderTag: "v0.6.0"}})
+	assert.Error(t, err)
+	assert.Empty(t, runner.Strings())
+
+	// API versions apply to native builds too.
+	matrix, err := b.BuildMatrix(context.Background(), ToolchainNative, b.Context.Modules, []MatrixEntry{{APIVersion: "0.16.0"}})
+	require.NoError(t, err)
+	require.Len(t, matrix, 1)
+	assert.True(t, matrix[0].Results[0].Succeeded)
+}
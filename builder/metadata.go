@@ -0,0 +1,108 @@
+package builder
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/suborbital/subo/builder/wasm"
+	"github.com/suborbital/subo/project"
+	"github.com/suborbital/subo/subo/release"
+	"github.com/suborbital/subo/subo/util"
+)
+
+// MetadataSectionName is the name of the custom section that build metadata is embedded in.
+const MetadataSectionName = "suborbital.build"
+
+// BuildMetadata describes how a module was built. It deliberately contains no timestamps
+// so that building the same sources with the same toolchain produces the same module.
+type BuildMetadata struct {
+	Module      string `json:"module"`
+	Namespace   string `json:"namespace"`
+	Lang        string `json:"lang"`
+	APIVersion  string `json:"apiVersion,omitempty"`
+	Toolchain   string `json:"toolchain"`
+	BuilderTag  string `json:"builderTag"`
+	SuboVersion string `json:"suboVersion"`
+	GitSHA      string `json:"gitSha,omitempty"`
+	GitDirty    bool   `json:"gitDirty"`
+}
+
+// metadataForModule gathers the build metadata for a module, including the git
+// commit of the module directory if it is within a git repository.
+func (b *Builder) metadataForModule(mod project.ModuleDir, tcn Toolchain) BuildMetadata {
+	meta := BuildMetadata{
+		Module:      mod.Name,
+		Namespace:   mod.Module.Namespace,
+		Lang:        mod.Module.Lang,
+		APIVersion:  mod.Module.APIVersion,
+		Toolchain:   string(tcn),
+		BuilderTag:  b.Context.BuilderTag,
+		SuboVersion: release.SuboVersion,
+	}
+
+	// git is queried silently as its absence (or the module not being in a repo) is not an error.
+	git := util.NewCommandLineExecutor(util.SilentOutput, nil)
+
+	sha, err := git.RunInDir("git rev-parse HEAD", mod.Fullpath)
+	if err != nil {
+		return meta
+	}
+
+	meta.GitSHA = strings.TrimSpace(sha)
+
+	if status, err := git.RunInDir("git status --porcelain -- .", mod.Fullpath); err == nil {
+		meta.GitDirty = strings.TrimSpace(status) != ""
+	}
+
+	return meta
+}
+
+// EmbedMetadata writes the metadata into a custom section of the module's .wasm file, replacing any existing metadata.
+func EmbedMetadata(mod project.ModuleDir, meta BuildMetadata) error {
+	wasmBytes, err := ioutil.ReadFile(wasmPath(mod))
+	if err != nil {
+		return errors.Wrap(err, "failed to ReadFile")
+	}
+
+	parsed, err := wasm.Parse(wasmBytes)
+	if err != nil {
+		return errors.Wrapf(err, "%s is not a valid Wasm module", wasmPath(mod))
+	}
+
+	metaBytes, err := json.Marshal(meta)
+	if err != nil {
+		return errors.Wrap(err, "failed to Marshal metadata")
+	}
+
+	parsed.SetCustomSection(MetadataSectionName, metaBytes)
+
+	if err := ioutil.WriteFile(wasmPath(mod), parsed.Encode(), util.PermFile); err != nil {
+		return errors.Wrap(err, "failed to WriteFile")
+	}
+
+	return nil
+}
+
+// ReadMetadata reads the build metadata embedded in a Wasm module, returning os.ErrNotExist if there is none.
+func ReadMetadata(wasmBytes []byte) (*BuildMetadata, error) {
+	parsed, err := wasm.Parse(wasmBytes)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to Parse")
+	}
+
+	metaBytes, exists := parsed.CustomSection(MetadataSectionName)
+	if !exists {
+		return nil, os.ErrNotExist
+	}
+
+	meta := &BuildMetadata{}
+	if err := json.Unmarshal(metaBytes, meta); err != nil {
+		return nil, errors.Wrap(err, "failed to Unmarshal metadata")
+	}
+
+	return meta, nil
+}
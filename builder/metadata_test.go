@@ -0,0 +1,134 @@
+package builder
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/suborbital/subo/project"
+	"github.com/suborbital/subo/subo/util"
+	"github.com/suborbital/systemspec/tenant"
+)
+
+func TestEmbedMetadata(t *testing.T) {
+	mod := project.ModuleDir{
+		Name:     "hello",
+		Fullpath: t.TempDir(),
+		Module:   &tenant.Module{Name: "hello", Lang: "rust"},
+	}
+
+	emptyModule := []byte{0x00, 0x61, 0x73, 0x6d, 0x01, 0x00, 0x00, 0x00}
+	require.NoError(t, os.WriteFile(wasmPath(mod), emptyModule, 0644))
+
+	_, err := ReadMetadata(emptyModule)
+	assert.ErrorIs(t, err, os.ErrNotExist)
+
+	first := BuildMetadata{Module: "hello", Lang: "rust", BuilderTag: "v1", GitSHA: "abc", GitDirty: true}
+	second := BuildMetadata{Module: "hello", Lang: "rust", BuilderTag: "v2"}
+
+	require.NoError(t, EmbedMetadata(mod, first))
+	require.NoError(t, EmbedMetadata(mod, second))
+
+	wasmBytes, err := os.ReadFile(filepath.Join(mod.Fullpath, "hello.wasm"))
+	require.NoError(t, err)
+
+	meta, err := ReadMetadata(wasmBytes)
+	require.NoError(t, err)
+	assert.Equal(t, &second, meta)
+}
+
+// writeEmptyModule returns a command handler that writes an empty module for every command, as a build would.
+func writeEmptyModule(mod project.ModuleDir) func(context.Context, util.Cmd) (*util.CmdResult, error) {
+	return func(ctx context.Context, cmd util.Cmd) (*util.CmdResult, error) {
+		emptyModule := []byte{0x00, 0x61, 0x73, 0x6d, 0x01, 0x00, 0x00, 0x00}
+		if err := os.WriteFile(wasmPath(mod), emptyModule, 0644); err != nil {
+			return nil, err
+		}
+
+		return testCommands{}.result(ctx, cmd)
+	}
+}
+
+func TestBuilder_EmbedMetadata(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, ".module.yaml"), []byte("name: hello\nlang: wat\n"), 0644))
+
+	for _, args := range [][]string{
+		{"init", "-q"},
+		{"add", "."},
+		{"-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "-q", "-m", "initial"},
+	} {
+		git := exec.Command("git", args...)
+		git.Dir = dir
+		out, err := git.CombinedOutput()
+		require.NoError(t, err, string(out))
+	}
+
+	mod := project.ModuleDir{Name: "hello", Fullpath: dir, Module: &tenant.Module{Name: "hello", Namespace: "default", Lang: "wat"}}
+	runner := &util.RecordingRunner{Handler: writeEmptyModule(mod)}
+
+	b := &Builder{
+		Context: &project.Context{Modules: []project.ModuleDir{mod}},
+		Config:  &BuildConfig{JsToolchain: "npm", CommandRunner: runner, Jobs: 1},
+		log:     &silentLogger{},
+	}
+
+	t.Run("is not embedded by default", func(t *testing.T) {
+		require.NoError(t, b.BuildWithToolchain(context.Background(), ToolchainNative))
+
+		wasmBytes, err := os.ReadFile(wasmPath(mod))
+		require.NoError(t, err)
+
+		_, err = ReadMetadata(wasmBytes)
+		assert.ErrorIs(t, err, os.ErrNotExist)
+	})
+
+	t.Run("records the git state from before the build", func(t *testing.T) {
+		require.NoError(t, os.Remove(wasmPath(mod)))
+		b.Config.EmbedMetadata = true
+
+		require.NoError(t, b.BuildWithToolchain(context.Background(), ToolchainNative))
+
+		wasmBytes, err := os.ReadFile(wasmPath(mod))
+		require.NoError(t, err)
+
+		meta, err := ReadMetadata(wasmBytes)
+		require.NoError(t, err)
+		assert.NotEmpty(t, meta.GitSHA)
+		// The built hello.wasm is untracked, so the module would be dirty if the state was checked afterwards.
+		assert.False(t, meta.GitDirty)
+	})
+
+	t.Run("is not restored from the cache", func(t *testing.T) {
+		b.cache = &BuildCache{dir: t.TempDir()}
+		defer func() { b.cache = nil }()
+
+		// The version commands aren't run, as the tools may not be installed.
+		b.toolVersions = map[string]string{"wat": "wat2wasm 1.0\n"}
+
+		require.NoError(t, os.Remove(wasmPath(mod)))
+		require.NoError(t, b.BuildWithToolchain(context.Background(), ToolchainNative))
+
+		require.NoError(t, os.Remove(wasmPath(mod)))
+		b.Config.EmbedMetadata = false
+
+		require.NoError(t, b.BuildWithToolchain(context.Background(), ToolchainNative))
+		require.Len(t, b.results, 1)
+		assert.True(t, b.results[0].CacheHit)
+
+		wasmBytes, err := os.ReadFile(wasmPath(mod))
+		require.NoError(t, err)
+
+		_, err = ReadMetadata(wasmBytes)
+		assert.ErrorIs(t, err, os.ErrNotExist)
+	})
+}
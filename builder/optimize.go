@@ -0,0 +1,43 @@
+package builder
+
+import (
+	"io/ioutil"
+
+	"github.com/pkg/errors"
+
+	"github.com/suborbital/subo/builder/wasm"
+	"github.com/suborbital/subo/project"
+	"github.com/suborbital/subo/subo/util"
+)
+
+// OptimizeWasmFile strips the custom sections (debug names, producers etc) from a module's
+// built .wasm file, returning its size before and after.
+func OptimizeWasmFile(mod project.ModuleDir) (int64, int64, error) {
+	wasmBytes, err := ioutil.ReadFile(wasmPath(mod))
+	if err != nil {
+		return 0, 0, errors.Wrap(err, "failed to ReadFile")
+	}
+
+	parsed, err := wasm.Parse(wasmBytes)
+	if err != nil {
+		return 0, 0, errors.Wrapf(err, "%s is not a valid Wasm module", wasmPath(mod))
+	}
+
+	if saved := parsed.StripCustomSections(); saved == 0 {
+		return int64(len(wasmBytes)), int64(len(wasmBytes)), nil
+	}
+
+	optimized := parsed.Encode()
+
+	if err := ioutil.WriteFile(wasmPath(mod), optimized, util.PermFile); err != nil {
+		return 0, 0, errors.Wrap(err, "failed to WriteFile")
+	}
+
+	return int64(len(wasmBytes)), int64(len(optimized)), nil
+}
+
+// shouldOptimize returns true if the module should be optimized, either because
+// the builder is configured to optimize everything or the module asks for it.
+func (b *Builder) shouldOptimize(mod project.ModuleDir) bool {
+	return b.Config.Optimize || mod.Config.Optimize
+}
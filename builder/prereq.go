@@ -10,96 +10,27 @@ import (
 )
 
 // Prereq is a pre-requisite file paired with the native command needed to acquire that file (if it's missing).
-type Prereq struct {
-	File    string
-	Command string
+type Prereq project.Prereq
+
+// GetCommand takes a ModuleDir, and returns an executed template command string.
+func (p Prereq) GetCommand(b BuildConfig, md project.ModuleDir) (string, error) {
+	return p.expand(p.Command, b, md)
 }
 
-// PreRequisiteCommands is a map of OS : language : preReq.
-var PreRequisiteCommands = map[string]map[string][]Prereq{
-	"darwin": {
-		"rust":  {},
-		"swift": {},
-		"grain": {
-			Prereq{
-				File:    "_lib",
-				Command: "mkdir _lib",
-			},
-			Prereq{
-				File:    "_lib/_lib.tar.gz",
-				Command: "curl -L https://github.com/suborbital/reactr/archive/v{{ .ModuleDir.Module.APIVersion }}.tar.gz -o _lib/_lib.tar.gz",
-			},
-			Prereq{
-				File:    "_lib/suborbital",
-				Command: "tar --strip-components=3 -C _lib -xvzf _lib/_lib.tar.gz **/api/grain/suborbital/*",
-			},
-		},
-		"assemblyscript": {
-			Prereq{
-				File:    "node_modules",
-				Command: "{{ .BuildConfig.JsToolchain }} install",
-			},
-		},
-		"tinygo": {},
-		"typescript": {
-			Prereq{
-				File:    "node_modules",
-				Command: "{{ .BuildConfig.JsToolchain }} install",
-			},
-		},
-		"javascript": {
-			Prereq{
-				File:    "node_modules",
-				Command: "{{ .BuildConfig.JsToolchain }} install",
-			},
-		},
-		"wat": {},
-	},
-	"linux": {
-		"rust":  {},
-		"swift": {},
-		"grain": {
-			Prereq{
-				File:    "_lib",
-				Command: "mkdir _lib",
-			},
-			Prereq{
-				File:    "_lib/_lib.tar.gz",
-				Command: "curl -L https://github.com/suborbital/reactr/archive/v{{ .ModuleDir.Module.APIVersion }}.tar.gz -o _lib/_lib.tar.gz",
-			},
-			Prereq{
-				File:    "_lib/suborbital",
-				Command: "tar --wildcards --strip-components=3 -C _lib -xvzf _lib/_lib.tar.gz **/api/grain/suborbital/*",
-			},
-		},
-		"assemblyscript": {
-			Prereq{
-				File:    "node_modules",
-				Command: "{{ .BuildConfig.JsToolchain }} install",
-			},
-		},
-		"tinygo": {},
-		"typescript": {
-			Prereq{
-				File:    "node_modules",
-				Command: "{{ .BuildConfig.JsToolchain }} install",
-			},
-		},
-		"javascript": {
-			Prereq{
-				File:    "node_modules",
-				Command: "{{ .BuildConfig.JsToolchain }} install",
-			},
-		},
-		"wat": {},
-	},
+// GetOfflineCommand returns the executed template of the command used to acquire the prereq in offline builds.
+func (p Prereq) GetOfflineCommand(b BuildConfig, md project.ModuleDir) (string, error) {
+	return p.expand(p.OfflineCommand, b, md)
 }
 
-// GetCommand takes a ModuleDir, and returns an executed template command string.
-func (p Prereq) GetCommand(b BuildConfig, md project.ModuleDir) (string, error) {
-	cmdTmpl, err := template.New("cmd").Parse(p.Command)
+// GetMirrorPath returns the executed template of the prereq's path within the offline mirror.
+func (p Prereq) GetMirrorPath(b BuildConfig, md project.ModuleDir) (string, error) {
+	return p.expand(p.Mirror, b, md)
+}
+
+func (p Prereq) expand(tmpl string, b BuildConfig, md project.ModuleDir) (string, error) {
+	cmdTmpl, err := template.New("cmd").Parse(tmpl)
 	if err != nil {
-		return "", errors.Wrapf(err, "failed to parse prerequisite Command string into template: %s", p.Command)
+		return "", errors.Wrapf(err, "failed to parse prerequisite Command string into template: %s", tmpl)
 	}
 
 	type TemplateParams struct {
@@ -1,6 +1,10 @@
 package builder
 
 import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -67,3 +71,63 @@ func TestPrereq_GetCommand(t *testing.T) {
 		})
 	}
 }
+
+func TestBuilder_checkAndRunPreReqs_Offline(t *testing.T) {
+	err := project.RegisterLanguage(project.Language{
+		Name: "offline-test",
+		Prereqs: map[string][]project.Prereq{
+			runtime.GOOS: {
+				{File: "_lib/_lib.tar.gz", Command: "curl -o _lib/_lib.tar.gz", Mirror: "lib/v{{ .ModuleDir.Module.APIVersion }}.tar.gz"},
+				{File: "node_modules", Command: "npm install", OfflineCommand: "npm install --offline"},
+			},
+		},
+	})
+	assert.NoError(t, err)
+
+	mirrorDir := t.TempDir()
+	assert.NoError(t, os.MkdirAll(filepath.Join(mirrorDir, "lib"), 0700))
+	assert.NoError(t, os.WriteFile(filepath.Join(mirrorDir, "lib", "v1.2.3.tar.gz"), []byte("lib"), 0600))
+
+	newModule := func(apiVersion string) project.ModuleDir {
+		dir := t.TempDir()
+		assert.NoError(t, os.MkdirAll(filepath.Join(dir, "_lib"), 0700))
+
+		return project.ModuleDir{
+			Name:     "offline",
+			Fullpath: dir,
+			Module:   &tenant.Module{Name: "offline", Lang: "offline-test", APIVersion: apiVersion},
+		}
+	}
+
+	t.Run("resolves prereqs from the mirror and offline commands", func(t *testing.T) {
+		runner := testCommands{}.runner()
+		b := testBuilder(runner, 1)
+		b.Config.Offline = true
+		b.Config.MirrorDir = mirrorDir
+
+		mod := newModule("1.2.3")
+
+		assert.NoError(t, b.checkAndRunPreReqs(context.Background(), mod, &BuildResult{}))
+
+		copied, err := os.ReadFile(filepath.Join(mod.Fullpath, "_lib", "_lib.tar.gz"))
+		assert.NoError(t, err)
+		assert.Equal(t, "lib", string(copied))
+
+		assert.Equal(t, []string{"npm install --offline"}, runner.Strings())
+	})
+
+	t.Run("reports prereqs missing from the mirror", func(t *testing.T) {
+		runner := testCommands{}.runner()
+		b := testBuilder(runner, 1)
+		b.Config.Offline = true
+		b.Config.MirrorDir = mirrorDir
+
+		err := b.checkAndRunPreReqs(context.Background(), newModule("9.9.9"), &BuildResult{})
+		if assert.Error(t, err) {
+			assert.Contains(t, err.Error(), filepath.Join(mirrorDir, "lib", "v9.9.9.tar.gz"))
+			assert.Contains(t, err.Error(), "\n  - node_modules (not attempted, as an earlier prerequisite is missing)")
+		}
+
+		assert.Empty(t, runner.Strings(), "no commands should run once a prereq is missing")
+	})
+}
@@ -0,0 +1,184 @@
+package builder
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ReportFormat is a machine-readable format that build results can be written in.
+type ReportFormat string
+
+const (
+	ReportFormatJSON  = ReportFormat("json")
+	ReportFormatJUnit = ReportFormat("junit")
+)
+
+// BuildReport is the machine-readable report of a build.
+type BuildReport struct {
+	Succeeded bool           `json:"succeeded"`
+	Modules   []ModuleReport `json:"modules"`
+}
+
+// ModuleReport is the machine-readable report of a single module's build.
+type ModuleReport struct {
+	Name       string `json:"name"`
+	Namespace  string `json:"namespace"`
+	Lang       string `json:"lang"`
+	Toolchain  string `json:"toolchain"`
+	Succeeded  bool   `json:"succeeded"`
+	CacheHit   bool   `json:"cacheHit"`
+	DurationMS int64  `json:"durationMs"`
+	WasmPath   string `json:"wasmPath,omitempty"`
+	Size       int64  `json:"size,omitempty"`
+	// UnoptimizedSize and SavedBytes are only set for modules that were optimized.
+	UnoptimizedSize int64  `json:"unoptimizedSize,omitempty"`
+	SavedBytes      int64  `json:"savedBytes,omitempty"`
+	Ref             string `json:"ref,omitempty"`
+	OutputLog       string `json:"outputLog"`
+	ErrorLog        string `json:"errorLog,omitempty"`
+	Error           string `json:"error,omitempty"`
+}
+
+// NewBuildReport creates a BuildReport from a set of build results.
+func NewBuildReport(results []BuildResult) *BuildReport {
+	report := &BuildReport{
+		Succeeded: true,
+		Modules:   []ModuleReport{},
+	}
+
+	for _, r := range results {
+		if !r.Succeeded {
+			report.Succeeded = false
+		}
+
+		var saved int64
+		if r.UnoptimizedSize > 0 {
+			saved = r.UnoptimizedSize - r.Size
+		}
+
+		report.Modules = append(report.Modules, ModuleReport{
+			Name:            r.Name,
+			Namespace:       r.Namespace,
+			Lang:            r.Lang,
+			Toolchain:       string(r.Toolchain),
+			Succeeded:       r.Succeeded,
+			CacheHit:        r.CacheHit,
+			DurationMS:      r.Duration.Milliseconds(),
+			WasmPath:        r.WasmPath,
+			Size:            r.Size,
+			UnoptimizedSize: r.UnoptimizedSize,
+			SavedBytes:      saved,
+			Ref:             r.Ref,
+			OutputLog:       r.OutputLog,
+			ErrorLog:        r.ErrorLog,
+			Error:           r.Error,
+		})
+	}
+
+	return report
+}
+
+// Write writes the report to w in the given format.
+func (r *BuildReport) Write(w io.Writer, format ReportFormat) error {
+	switch format {
+	case ReportFormatJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+
+		if err := enc.Encode(r); err != nil {
+			return errors.Wrap(err, "failed to Encode JSON report")
+		}
+	case ReportFormatJUnit:
+		if _, err := io.WriteString(w, xml.Header); err != nil {
+			return errors.Wrap(err, "failed to WriteString")
+		}
+
+		enc := xml.NewEncoder(w)
+		enc.Indent("", "  ")
+
+		if err := enc.Encode(r.junit()); err != nil {
+			return errors.Wrap(err, "failed to Encode JUnit report")
+		}
+
+		if _, err := io.WriteString(w, "\n"); err != nil {
+			return errors.Wrap(err, "failed to WriteString")
+		}
+	default:
+		return fmt.Errorf("%s is not a valid report format", format)
+	}
+
+	return nil
+}
+
+type junitTestSuite struct {
+	XMLName  xml.Name        `xml:"testsuite"`
+	Name     string          `xml:"name,attr"`
+	Tests    int             `xml:"tests,attr"`
+	Failures int             `xml:"failures,attr"`
+	Time     string          `xml:"time,attr"`
+	Cases    []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+	SystemErr string        `xml:"system-err,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Body    string `xml:",chardata"`
+}
+
+// junit converts the report into a JUnit test suite with one test case per module.
+func (r *BuildReport) junit() *junitTestSuite {
+	suite := &junitTestSuite{
+		Name:  "subo build",
+		Tests: len(r.Modules),
+		Cases: []junitTestCase{},
+	}
+
+	var total time.Duration
+
+	for _, m := range r.Modules {
+		duration := time.Duration(m.DurationMS) * time.Millisecond
+		total += duration
+
+		tc := junitTestCase{
+			Name:      m.Name,
+			ClassName: fmt.Sprintf("%s.%s", m.Namespace, m.Lang),
+			Time:      fmt.Sprintf("%.3f", duration.Seconds()),
+			SystemErr: m.ErrorLog,
+		}
+
+		if m.Succeeded {
+			tc.SystemOut = m.OutputLog
+		} else {
+			suite.Failures++
+
+			message := fmt.Sprintf("failed to build %s", m.Name)
+			if m.Error != "" {
+				message = fmt.Sprintf("%s: %s", message, m.Error)
+			}
+
+			tc.Failure = &junitFailure{
+				Message: message,
+				Body:    m.OutputLog,
+			}
+		}
+
+		suite.Cases = append(suite.Cases, tc)
+	}
+
+	suite.Time = fmt.Sprintf("%.3f", total.Seconds())
+
+	return suite
+}
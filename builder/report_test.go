@@ -0,0 +1,67 @@
+package builder
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuildReport_Write(t *testing.T) {
+	results := []BuildResult{
+		{
+			Name:      "hello",
+			Namespace: "default",
+			Lang:      "rust",
+			Toolchain: ToolchainNative,
+			Succeeded: true,
+			Duration:  1500 * time.Millisecond,
+			WasmPath:  "/project/hello/hello.wasm",
+			Size:      42,
+			Ref:       "abc123",
+			OutputLog: "compiled hello",
+		},
+		{
+			Name:      "goodbye",
+			Namespace: "default",
+			Lang:      "tinygo",
+			Toolchain: ToolchainNative,
+			Succeeded: false,
+			Duration:  250 * time.Millisecond,
+			OutputLog: "syntax error",
+			ErrorLog:  "syntax error",
+			Error:     "exit status 1",
+		},
+	}
+
+	report := NewBuildReport(results)
+	assert.False(t, report.Succeeded)
+
+	t.Run("json", func(t *testing.T) {
+		buf := &bytes.Buffer{}
+		require.NoError(t, report.Write(buf, ReportFormatJSON))
+
+		decoded := &BuildReport{}
+		require.NoError(t, json.Unmarshal(buf.Bytes(), decoded))
+		assert.Equal(t, report, decoded)
+		assert.Equal(t, int64(1500), decoded.Modules[0].DurationMS)
+	})
+
+	t.Run("junit", func(t *testing.T) {
+		buf := &bytes.Buffer{}
+		require.NoError(t, report.Write(buf, ReportFormatJUnit))
+
+		out := buf.String()
+		assert.True(t, strings.Contains(out, `<testsuite name="subo build" tests="2" failures="1" time="1.750">`))
+		assert.True(t, strings.Contains(out, `<failure message="failed to build goodbye: exit status 1">syntax error</failure>`))
+		assert.True(t, strings.Contains(out, `<system-err>syntax error</system-err>`))
+	})
+
+	t.Run("invalid format", func(t *testing.T) {
+		assert.Error(t, report.Write(&bytes.Buffer{}, ReportFormat("yaml")))
+	})
+}
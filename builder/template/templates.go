@@ -1,6 +1,7 @@
 package template
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -239,9 +240,6 @@ func downloadZip(repo, branch, targetPath string) (string, error) {
 
 // extractZip extracts a ZIP file.
 func extractZip(filePath, destPath, branchDirName string) (string, error) {
-	escapedFilepath := strings.ReplaceAll(filePath, " ", "\\ ")
-	escapedDestPath := strings.ReplaceAll(destPath, " ", "\\ ") + string(filepath.Separator)
-
 	existingPath := filepath.Join(destPath, branchDirName)
 
 	if _, err := os.Stat(existingPath); err == nil {
@@ -250,7 +248,8 @@ func extractZip(filePath, destPath, branchDirName string) (string, error) {
 		}
 	}
 
-	if _, err := util.Command.Run(fmt.Sprintf("unzip -q %s -d %s", escapedFilepath, escapedDestPath)); err != nil {
+	// The paths are passed as arguments rather than through a shell so that they don't need escaping.
+	if _, err := util.Command.Exec(context.Background(), util.ArgsCmd("unzip", "-q", filePath, "-d", destPath+string(filepath.Separator))); err != nil {
 		return "", errors.Wrap(err, "failed to Run unzip")
 	}
 
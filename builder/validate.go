@@ -0,0 +1,102 @@
+package builder
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/hashicorp/go-version"
+	"github.com/pkg/errors"
+
+	"github.com/suborbital/subo/builder/wasm"
+	"github.com/suborbital/subo/project"
+)
+
+// wasmRequirement is what E2Core requires of modules built against a minimum API version.
+type wasmRequirement struct {
+	minAPIVersion string
+	// exports are the functions the module must export.
+	exports []string
+	// importModules are the only modules imports may be taken from.
+	importModules []string
+}
+
+// wasmRequirements are ordered from newest to oldest API version. A requirement applies to
+// modules with an API version from its minAPIVersion up to the next newest requirement's.
+var wasmRequirements = []wasmRequirement{
+	{
+		minAPIVersion: "0.0.0",
+		exports:       []string{"allocate", "deallocate", "run_e"},
+		importModules: []string{"env", "wasi_snapshot_preview1"},
+	},
+}
+
+// ValidateWasmFile parses a module's built .wasm file and ensures that it is a valid
+// WebAssembly binary with the exports and imports required by its API version.
+func ValidateWasmFile(mod project.ModuleDir) error {
+	wasmBytes, err := ioutil.ReadFile(wasmPath(mod))
+	if err != nil {
+		return errors.Wrap(err, "failed to ReadFile")
+	}
+
+	parsed, err := wasm.Parse(wasmBytes)
+	if err != nil {
+		return errors.Wrapf(err, "%s is not a valid Wasm module", wasmPath(mod))
+	}
+
+	req := requirementForAPIVersion(mod.Module.APIVersion)
+
+	missing := []string{}
+	for _, e := range req.exports {
+		if !parsed.HasExport(e) {
+			missing = append(missing, e)
+		}
+	}
+
+	if len(missing) > 0 {
+		return fmt.Errorf("%s is missing exports required by API version %s: %s", mod.Name, apiVersionName(mod), strings.Join(missing, ", "))
+	}
+
+	allowed := map[string]bool{}
+	for _, m := range req.importModules {
+		allowed[m] = true
+	}
+
+	disallowed := []string{}
+	for _, m := range parsed.ImportModules() {
+		if !allowed[m] {
+			disallowed = append(disallowed, m)
+		}
+	}
+
+	if len(disallowed) > 0 {
+		return fmt.Errorf("%s imports from modules not supported by API version %s: %s (supported: %s)", mod.Name, apiVersionName(mod), strings.Join(disallowed, ", "), strings.Join(req.importModules, ", "))
+	}
+
+	return nil
+}
+
+// requirementForAPIVersion returns the newest requirement that applies to the API version.
+// Modules with a missing or invalid API version get the newest requirement.
+func requirementForAPIVersion(apiVersion string) wasmRequirement {
+	modVersion, err := version.NewVersion(apiVersion)
+	if err != nil {
+		return wasmRequirements[0]
+	}
+
+	for _, req := range wasmRequirements {
+		if modVersion.GreaterThanOrEqual(version.Must(version.NewVersion(req.minAPIVersion))) {
+			return req
+		}
+	}
+
+	return wasmRequirements[len(wasmRequirements)-1]
+}
+
+func apiVersionName(mod project.ModuleDir) string {
+	if mod.Module.APIVersion == "" {
+		return "(unset)"
+	}
+
+	return mod.Module.APIVersion
+}
@@ -0,0 +1,111 @@
+package builder
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/suborbital/subo/project"
+	"github.com/suborbital/systemspec/tenant"
+)
+
+// testWasm returns a Wasm binary exporting the given functions and importing a function from each of the given modules.
+func testWasm(exports, importModules []string) []byte {
+	name := func(s string) []byte {
+		return append([]byte{byte(len(s))}, []byte(s)...)
+	}
+
+	section := func(id byte, count int, entries []byte) []byte {
+		contents := append([]byte{byte(count)}, entries...)
+		return append([]byte{id, byte(len(contents))}, contents...)
+	}
+
+	imports := []byte{}
+	for _, m := range importModules {
+		imports = append(append(append(imports, name(m)...), name("fn")...), 0x00, 0x00)
+	}
+
+	exported := []byte{}
+	for _, e := range exports {
+		exported = append(append(exported, name(e)...), 0x00, 0x00)
+	}
+
+	bin := []byte{0x00, 0x61, 0x73, 0x6d, 0x01, 0x00, 0x00, 0x00}
+	bin = append(bin, section(0x02, len(importModules), imports)...)
+
+	return append(bin, section(0x07, len(exports), exported)...)
+}
+
+func TestValidateWasmFile(t *testing.T) {
+	mod := project.ModuleDir{
+		Name:     "hello",
+		Fullpath: t.TempDir(),
+		Module:   &tenant.Module{Name: "hello", Lang: "rust", APIVersion: "0.15.1"},
+	}
+
+	tests := []struct {
+		name          string
+		exports       []string
+		importModules []string
+		wantErr       string
+	}{
+		{
+			name:          "valid",
+			exports:       []string{"allocate", "deallocate", "run_e"},
+			importModules: []string{"env", "wasi_snapshot_preview1"},
+		},
+		{
+			name:    "missing exports",
+			exports: []string{"run_e"},
+			wantErr: "hello is missing exports required by API version 0.15.1: allocate, deallocate",
+		},
+		{
+			name:          "unsupported imports",
+			exports:       []string{"allocate", "deallocate", "run_e"},
+			importModules: []string{"env", "wasi_unstable"},
+			wantErr:       "hello imports from modules not supported by API version 0.15.1: wasi_unstable (supported: env, wasi_snapshot_preview1)",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.NoError(t, os.WriteFile(wasmPath(mod), testWasm(tt.exports, tt.importModules), 0644))
+
+			err := ValidateWasmFile(mod)
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tt.wantErr)
+			}
+		})
+	}
+
+	require.NoError(t, os.WriteFile(wasmPath(mod), []byte("not wasm"), 0644))
+	assert.Error(t, ValidateWasmFile(mod))
+}
+
+func TestRequirementForAPIVersion(t *testing.T) {
+	defer func(reqs []wasmRequirement) { wasmRequirements = reqs }(wasmRequirements)
+
+	wasmRequirements = []wasmRequirement{
+		{minAPIVersion: "0.16.0", exports: []string{"new"}},
+		{minAPIVersion: "0.10.0", exports: []string{"middle"}},
+		{minAPIVersion: "0.0.0", exports: []string{"old"}},
+	}
+
+	tests := map[string]string{
+		"0.16.0":  "new",
+		"0.17.2":  "new",
+		"0.15.1":  "middle",
+		"0.10.0":  "middle",
+		"0.9.9":   "old",
+		"":        "new",
+		"invalid": "new",
+	}
+
+	for apiVersion, want := range tests {
+		assert.Equal(t, []string{want}, requirementForAPIVersion(apiVersion).exports, apiVersion)
+	}
+}
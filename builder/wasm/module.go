@@ -0,0 +1,336 @@
+package wasm
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/pkg/errors"
+)
+
+// Section IDs as defined by the WebAssembly binary format.
+const (
+	SectionCustom    = byte(0)
+	SectionType      = byte(1)
+	SectionImport    = byte(2)
+	SectionFunction  = byte(3)
+	SectionTable     = byte(4)
+	SectionMemory    = byte(5)
+	SectionGlobal    = byte(6)
+	SectionExport    = byte(7)
+	SectionStart     = byte(8)
+	SectionElement   = byte(9)
+	SectionCode      = byte(10)
+	SectionData      = byte(11)
+	SectionDataCount = byte(12)
+	SectionTag       = byte(13)
+)
+
+// External kinds used by imports and exports.
+const (
+	KindFunc   = byte(0)
+	KindTable  = byte(1)
+	KindMemory = byte(2)
+	KindGlobal = byte(3)
+	KindTag    = byte(4)
+)
+
+var (
+	magic   = []byte{0x00, 0x61, 0x73, 0x6d}
+	version = []byte{0x01, 0x00, 0x00, 0x00}
+)
+
+// Module is a parsed WebAssembly binary. Sections are kept in their original
+// (binary) form so that the module can be re-encoded after being modified.
+type Module struct {
+	Sections []Section
+	Imports  []Import
+	Exports  []Export
+}
+
+// Section is a single section of a WebAssembly binary.
+type Section struct {
+	ID byte
+	// Name is only set for custom sections.
+	Name string
+	// Data is the full contents of the section, excluding its ID and size.
+	Data []byte
+}
+
+// Import is an entry in a module's import section.
+type Import struct {
+	Module string
+	Name   string
+	Kind   byte
+}
+
+// Export is an entry in a module's export section.
+type Export struct {
+	Name  string
+	Kind  byte
+	Index uint32
+}
+
+// Parse parses and validates the structure of a WebAssembly binary.
+func Parse(data []byte) (*Module, error) {
+	r := &reader{data: data}
+
+	header, err := r.bytes(8)
+	if err != nil || !bytes.Equal(header[:4], magic) {
+		return nil, errors.New("not a WebAssembly binary (missing magic number)")
+	}
+
+	if !bytes.Equal(header[4:], version) {
+		return nil, fmt.Errorf("unsupported WebAssembly binary version %v", header[4:])
+	}
+
+	mod := &Module{
+		Sections: []Section{},
+		Imports:  []Import{},
+		Exports:  []Export{},
+	}
+
+	seen := map[byte]bool{}
+
+	for !r.done() {
+		id, err := r.byte()
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to read section id")
+		}
+
+		if id > SectionTag {
+			return nil, fmt.Errorf("invalid section id %d at offset %d", id, r.pos-1)
+		}
+
+		if id != SectionCustom {
+			if seen[id] {
+				return nil, fmt.Errorf("duplicate section id %d", id)
+			}
+
+			seen[id] = true
+		}
+
+		size, err := r.u32()
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to read size of section %d", id)
+		}
+
+		contents, err := r.bytes(int(size))
+		if err != nil {
+			return nil, errors.Wrapf(err, "section %d is truncated", id)
+		}
+
+		section := Section{ID: id, Data: contents}
+		sr := &reader{data: contents}
+
+		switch id {
+		case SectionCustom:
+			name, err := sr.name()
+			if err != nil {
+				return nil, errors.Wrap(err, "failed to read custom section name")
+			}
+
+			section.Name = name
+		case SectionImport:
+			imports, err := parseImports(sr)
+			if err != nil {
+				return nil, errors.Wrap(err, "failed to parse import section")
+			}
+
+			mod.Imports = imports
+		case SectionExport:
+			exports, err := parseExports(sr)
+			if err != nil {
+				return nil, errors.Wrap(err, "failed to parse export section")
+			}
+
+			mod.Exports = exports
+		}
+
+		mod.Sections = append(mod.Sections, section)
+	}
+
+	return mod, nil
+}
+
+// HasExport returns true if the module exports a function with the given name.
+func (m *Module) HasExport(name string) bool {
+	for _, e := range m.Exports {
+		if e.Name == name && e.Kind == KindFunc {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ImportModules returns the names of the modules that imports are taken from, e.g. wasi_snapshot_preview1.
+func (m *Module) ImportModules() []string {
+	seen := map[string]bool{}
+	modules := []string{}
+
+	for _, i := range m.Imports {
+		if !seen[i.Module] {
+			seen[i.Module] = true
+			modules = append(modules, i.Module)
+		}
+	}
+
+	return modules
+}
+
+func parseImports(r *reader) ([]Import, error) {
+	count, err := r.u32()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read import count")
+	}
+
+	imports := []Import{}
+
+	for i := uint32(0); i < count; i++ {
+		module, err := r.name()
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to read import module")
+		}
+
+		name, err := r.name()
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to read import name")
+		}
+
+		kind, err := r.byte()
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to read import kind")
+		}
+
+		if err := r.skipImportDesc(kind); err != nil {
+			return nil, errors.Wrapf(err, "invalid import %s.%s", module, name)
+		}
+
+		imports = append(imports, Import{Module: module, Name: name, Kind: kind})
+	}
+
+	if !r.done() {
+		return nil, errors.New("unexpected data after imports")
+	}
+
+	return imports, nil
+}
+
+func parseExports(r *reader) ([]Export, error) {
+	count, err := r.u32()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read export count")
+	}
+
+	exports := []Export{}
+
+	for i := uint32(0); i < count; i++ {
+		name, err := r.name()
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to read export name")
+		}
+
+		kind, err := r.byte()
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to read export kind")
+		}
+
+		if kind > KindTag {
+			return nil, fmt.Errorf("invalid kind %d for export %s", kind, name)
+		}
+
+		index, err := r.u32()
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to read index for export %s", name)
+		}
+
+		exports = append(exports, Export{Name: name, Kind: kind, Index: index})
+	}
+
+	if !r.done() {
+		return nil, errors.New("unexpected data after exports")
+	}
+
+	return exports, nil
+}
+
+// StripCustomSections removes all custom sections (including the debug "name" section) from
+// the module, returning the number of bytes removed from the encoded module.
+func (m *Module) StripCustomSections() int {
+	before := len(m.Encode())
+
+	sections := []Section{}
+	for _, s := range m.Sections {
+		if s.ID != SectionCustom {
+			sections = append(sections, s)
+		}
+	}
+
+	m.Sections = sections
+
+	return before - len(m.Encode())
+}
+
+// Encode encodes the module back into the WebAssembly binary format.
+func (m *Module) Encode() []byte {
+	buf := &bytes.Buffer{}
+	buf.Write(magic)
+	buf.Write(version)
+
+	for _, s := range m.Sections {
+		buf.WriteByte(s.ID)
+		buf.Write(encodeU32(uint32(len(s.Data))))
+		buf.Write(s.Data)
+	}
+
+	return buf.Bytes()
+}
+
+// encodeU32 encodes an unsigned integer as LEB128.
+func encodeU32(v uint32) []byte {
+	out := []byte{}
+
+	for {
+		b := byte(v & 0x7f)
+		v >>= 7
+
+		if v == 0 {
+			return append(out, b)
+		}
+
+		out = append(out, b|0x80)
+	}
+}
+
+// CustomSection returns the payload (excluding the name) of the first custom section with the given name.
+func (m *Module) CustomSection(name string) ([]byte, bool) {
+	for _, s := range m.Sections {
+		if s.ID == SectionCustom && s.Name == name {
+			r := &reader{data: s.Data}
+
+			// The name was already validated by Parse or SetCustomSection.
+			_, _ = r.name()
+
+			return s.Data[r.pos:], true
+		}
+	}
+
+	return nil, false
+}
+
+// SetCustomSection adds a custom section with the given name and payload to the end of the
+// module, replacing any existing custom sections with the same name.
+func (m *Module) SetCustomSection(name string, payload []byte) {
+	sections := []Section{}
+	for _, s := range m.Sections {
+		if s.ID != SectionCustom || s.Name != name {
+			sections = append(sections, s)
+		}
+	}
+
+	data := encodeU32(uint32(len(name)))
+	data = append(data, []byte(name)...)
+	data = append(data, payload...)
+
+	m.Sections = append(sections, Section{ID: SectionCustom, Name: name, Data: data})
+}
@@ -0,0 +1,147 @@
+package wasm
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func name(s string) []byte {
+	return append([]byte{byte(len(s))}, []byte(s)...)
+}
+
+func section(id byte, contents ...[]byte) []byte {
+	data := []byte{}
+	for _, c := range contents {
+		data = append(data, c...)
+	}
+
+	return append([]byte{id, byte(len(data))}, data...)
+}
+
+func testBinary(sections ...[]byte) []byte {
+	bin := append([]byte{}, magic...)
+	bin = append(bin, version...)
+
+	for _, s := range sections {
+		bin = append(bin, s...)
+	}
+
+	return bin
+}
+
+func TestParse(t *testing.T) {
+	bin := testBinary(
+		section(SectionCustom, name("producers"), []byte{0x01, 0x02}),
+		section(SectionImport,
+			[]byte{0x02},
+			name("wasi_snapshot_preview1"), name("fd_write"), []byte{KindFunc, 0x00},
+			name("env"), name("memory"), []byte{KindMemory, 0x01, 0x01, 0x02},
+		),
+		section(SectionExport,
+			[]byte{0x02},
+			name("run_e"), []byte{KindFunc, 0x01},
+			name("memory"), []byte{KindMemory, 0x00},
+		),
+	)
+
+	mod, err := Parse(bin)
+	require.NoError(t, err)
+
+	assert.Len(t, mod.Sections, 3)
+	assert.Equal(t, "producers", mod.Sections[0].Name)
+	assert.Equal(t, []Import{
+		{Module: "wasi_snapshot_preview1", Name: "fd_write", Kind: KindFunc},
+		{Module: "env", Name: "memory", Kind: KindMemory},
+	}, mod.Imports)
+	assert.Equal(t, []string{"wasi_snapshot_preview1", "env"}, mod.ImportModules())
+
+	assert.True(t, mod.HasExport("run_e"))
+	assert.False(t, mod.HasExport("memory"))
+	assert.False(t, mod.HasExport("allocate"))
+}
+
+func TestParse_Invalid(t *testing.T) {
+	tests := []struct {
+		name string
+		bin  []byte
+	}{
+		{"empty", []byte{}},
+		{"bad magic", []byte{0x00, 0x61, 0x73, 0x00, 0x01, 0x00, 0x00, 0x00}},
+		{"bad version", []byte{0x00, 0x61, 0x73, 0x6d, 0x02, 0x00, 0x00, 0x00}},
+		{"invalid section id", testBinary([]byte{0x20, 0x00})},
+		{"truncated section", testBinary([]byte{SectionType, 0x05, 0x00})},
+		{"duplicate section", testBinary(section(SectionType, []byte{0x00}), section(SectionType, []byte{0x00}))},
+		{"truncated export", testBinary(section(SectionExport, []byte{0x01}, name("run_e")))},
+		{"invalid import kind", testBinary(section(SectionImport, []byte{0x01}, name("env"), name("x"), []byte{0x09, 0x00}))},
+		{"overlong integer", testBinary(section(SectionExport, []byte{0x80, 0x80, 0x80, 0x80, 0x80, 0x00}))},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse(tt.bin)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestModule_StripCustomSections(t *testing.T) {
+	exports := section(SectionExport, []byte{0x01}, name("run_e"), []byte{KindFunc, 0x00})
+
+	bin := testBinary(
+		section(SectionCustom, name("name"), []byte{0x00, 0x01, 0x02}),
+		exports,
+		section(SectionCustom, name(".debug_info"), []byte{0x03}),
+	)
+
+	mod, err := Parse(bin)
+	require.NoError(t, err)
+
+	// Re-encoding an unmodified module must be lossless.
+	assert.Equal(t, bin, mod.Encode())
+
+	saved := mod.StripCustomSections()
+	stripped := mod.Encode()
+
+	assert.Equal(t, testBinary(exports), stripped)
+	assert.Equal(t, len(bin)-len(stripped), saved)
+
+	reparsed, err := Parse(stripped)
+	require.NoError(t, err)
+	assert.True(t, reparsed.HasExport("run_e"))
+}
+
+func TestEncodeU32(t *testing.T) {
+	for _, v := range []uint32{0, 1, 127, 128, 300, 1 << 20, 1<<32 - 1} {
+		r := &reader{data: encodeU32(v)}
+
+		got, err := r.u32()
+		require.NoError(t, err)
+		assert.Equal(t, v, got)
+		assert.True(t, r.done())
+	}
+}
+
+func TestModule_SetCustomSection(t *testing.T) {
+	mod, err := Parse(testBinary(section(SectionCustom, name("meta"), []byte("old"))))
+	require.NoError(t, err)
+
+	payload, exists := mod.CustomSection("meta")
+	assert.True(t, exists)
+	assert.Equal(t, []byte("old"), payload)
+
+	mod.SetCustomSection("meta", []byte("new"))
+	mod.SetCustomSection("other", []byte("x"))
+
+	reparsed, err := Parse(mod.Encode())
+	require.NoError(t, err)
+	assert.Len(t, reparsed.Sections, 2)
+
+	payload, exists = reparsed.CustomSection("meta")
+	assert.True(t, exists)
+	assert.Equal(t, []byte("new"), payload)
+
+	_, exists = reparsed.CustomSection("missing")
+	assert.False(t, exists)
+}
@@ -0,0 +1,147 @@
+package wasm
+
+import (
+	"fmt"
+	"io"
+	"unicode/utf8"
+
+	"github.com/pkg/errors"
+)
+
+// reader reads the primitive values of the WebAssembly binary format from a byte slice.
+type reader struct {
+	data []byte
+	pos  int
+}
+
+func (r *reader) done() bool {
+	return r.pos >= len(r.data)
+}
+
+func (r *reader) byte() (byte, error) {
+	if r.done() {
+		return 0, io.ErrUnexpectedEOF
+	}
+
+	b := r.data[r.pos]
+	r.pos++
+
+	return b, nil
+}
+
+func (r *reader) bytes(n int) ([]byte, error) {
+	if n < 0 || r.pos+n > len(r.data) {
+		return nil, io.ErrUnexpectedEOF
+	}
+
+	b := r.data[r.pos : r.pos+n]
+	r.pos += n
+
+	return b, nil
+}
+
+// uleb reads an unsigned LEB128 integer of at most the given number of bits.
+func (r *reader) uleb(bits uint) (uint64, error) {
+	var result uint64
+	var shift uint
+
+	for {
+		b, err := r.byte()
+		if err != nil {
+			return 0, err
+		}
+
+		result |= uint64(b&0x7f) << shift
+
+		if b&0x80 == 0 {
+			break
+		}
+
+		shift += 7
+		if shift >= bits {
+			return 0, errors.New("integer representation too long")
+		}
+	}
+
+	if bits < 64 && result >= 1<<bits {
+		return 0, errors.New("integer too large")
+	}
+
+	return result, nil
+}
+
+func (r *reader) u32() (uint32, error) {
+	v, err := r.uleb(32)
+	return uint32(v), err
+}
+
+func (r *reader) name() (string, error) {
+	size, err := r.u32()
+	if err != nil {
+		return "", err
+	}
+
+	b, err := r.bytes(int(size))
+	if err != nil {
+		return "", err
+	}
+
+	if !utf8.Valid(b) {
+		return "", errors.New("name is not valid UTF-8")
+	}
+
+	return string(b), nil
+}
+
+// skipImportDesc skips over the description of an import of the given kind.
+func (r *reader) skipImportDesc(kind byte) error {
+	switch kind {
+	case KindFunc:
+		_, err := r.u32()
+		return err
+	case KindTable:
+		if _, err := r.byte(); err != nil {
+			return err
+		}
+
+		return r.skipLimits()
+	case KindMemory:
+		return r.skipLimits()
+	case KindGlobal:
+		// valtype and mutability.
+		_, err := r.bytes(2)
+		return err
+	case KindTag:
+		if _, err := r.byte(); err != nil {
+			return err
+		}
+
+		_, err := r.u32()
+		return err
+	}
+
+	return fmt.Errorf("invalid import kind %d", kind)
+}
+
+func (r *reader) skipLimits() error {
+	flags, err := r.byte()
+	if err != nil {
+		return err
+	}
+
+	if flags > 0x07 {
+		return fmt.Errorf("invalid limits flags %d", flags)
+	}
+
+	if _, err := r.uleb(64); err != nil {
+		return err
+	}
+
+	if flags&0x01 != 0 {
+		if _, err := r.uleb(64); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,228 @@
+package builder
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/suborbital/subo/project"
+)
+
+const (
+	defaultWatchInterval = 500 * time.Millisecond
+	defaultWatchDebounce = time.Second
+)
+
+// Watcher polls a builder's modules for changes to their source files and rebuilds the modules that changed.
+type Watcher struct {
+	Builder   *Builder
+	Toolchain Toolchain
+	// Interval is how often the module directories are checked for changes.
+	Interval time.Duration
+	// Debounce is how long the files must stay unchanged before a rebuild starts.
+	Debounce time.Duration
+
+	// snapshots are keyed by module key.
+	snapshots map[string]moduleSnapshot
+}
+
+// moduleSnapshot is the modification time and size of each source file in a module, keyed by path.
+type moduleSnapshot map[string]string
+
+// NewWatcher creates a Watcher for the given builder and toolchain.
+func NewWatcher(b *Builder, tcn Toolchain) *Watcher {
+	w := &Watcher{
+		Builder:   b,
+		Toolchain: tcn,
+		Interval:  defaultWatchInterval,
+		Debounce:  defaultWatchDebounce,
+		snapshots: map[string]moduleSnapshot{},
+	}
+
+	return w
+}
+
+// Watch blocks until ctx is cancelled, rebuilding modules as their files change. afterBuild is called
+// after each rebuild that succeeds, and is intended for re-running packaging jobs such as bundling.
+// Build failures are logged rather than returned so that the watcher keeps running.
+func (w *Watcher) Watch(ctx context.Context, afterBuild func() error) error {
+	if err := w.snapshotModules(w.Builder.Context.Modules); err != nil {
+		return errors.Wrap(err, "failed to snapshotModules")
+	}
+
+	w.Builder.log.LogInfo("👀 watching for changes (press Ctrl-C to stop)")
+
+	ticker := time.NewTicker(w.Interval)
+	defer ticker.Stop()
+
+	pending := map[string]bool{}
+	lastChange := time.Time{}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+
+		changed, err := w.changedModules()
+		if err != nil {
+			w.Builder.log.LogWarn(errors.Wrap(err, "failed to check for changes").Error())
+			continue
+		}
+
+		if len(changed) > 0 {
+			for _, key := range changed {
+				pending[key] = true
+			}
+
+			lastChange = time.Now()
+			continue
+		}
+
+		if len(pending) == 0 || time.Since(lastChange) < w.Debounce {
+			continue
+		}
+
+		w.rebuild(ctx, pending, afterBuild)
+
+		pending = map[string]bool{}
+	}
+}
+
+// rebuild builds the pending modules and then runs afterBuild if they all succeeded.
+func (w *Watcher) rebuild(ctx context.Context, pending map[string]bool, afterBuild func() error) {
+	changed := []project.ModuleDir{}
+	names := []string{}
+
+	for _, mod := range w.Builder.Context.Modules {
+		if pending[mod.Key()] {
+			changed = append(changed, mod)
+			names = append(names, mod.Name)
+		}
+	}
+
+	// Modules depending on the ones that changed are rebuilt too.
+	mods := w.Builder.Context.WithDependents(changed)
+
+	w.Builder.log.LogInfo(fmt.Sprintf("changes detected in %s, rebuilding %d modules", strings.Join(names, ", "), len(mods)))
+
+	// The snapshot is taken before building so that files edited during the build trigger another rebuild. Build
+	// outputs (.wasm files, target directories etc) aren't part of snapshots, so writing them doesn't trigger one.
+	if err := w.snapshotModules(mods); err != nil {
+		w.Builder.log.LogWarn(errors.Wrap(err, "failed to snapshotModules").Error())
+	}
+
+	if err := w.Builder.BuildModulesWithToolchain(ctx, w.Toolchain, mods); err != nil {
+		w.Builder.log.LogFail(err.Error())
+		return
+	}
+
+	if afterBuild != nil {
+		if err := afterBuild(); err != nil {
+			w.Builder.log.LogFail(err.Error())
+		}
+	}
+}
+
+// snapshotModules replaces the snapshots of the given modules with their current state.
+func (w *Watcher) snapshotModules(mods []project.ModuleDir) error {
+	for _, mod := range mods {
+		snapshot, err := snapshotModule(mod)
+		if err != nil {
+			return errors.Wrapf(err, "failed to snapshotModule %s", mod.Name)
+		}
+
+		w.snapshots[mod.Key()] = snapshot
+	}
+
+	return nil
+}
+
+// changedModules returns the keys of modules whose source files have changed since the last check.
+func (w *Watcher) changedModules() ([]string, error) {
+	changed := []string{}
+
+	for _, mod := range w.Builder.Context.Modules {
+		snapshot, err := snapshotModule(mod)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to snapshotModule %s", mod.Name)
+		}
+
+		if !snapshot.equal(w.snapshots[mod.Key()]) {
+			changed = append(changed, mod.Key())
+		}
+
+		w.snapshots[mod.Key()] = snapshot
+	}
+
+	return changed, nil
+}
+
+// snapshotModule records the source files in a module directory and its shared directories, ignoring build outputs.
+func snapshotModule(mod project.ModuleDir) (moduleSnapshot, error) {
+	snapshot := moduleSnapshot{}
+
+	for _, dir := range append([]string{mod.Fullpath}, mod.SharedDirPaths()...) {
+		if err := snapshotDir(mod.Module.Lang, dir, snapshot); err != nil {
+			return nil, errors.Wrapf(err, "failed to snapshotDir %s", dir)
+		}
+	}
+
+	return snapshot, nil
+}
+
+// snapshotDir adds the source files in dir (of a module in the given language) to the snapshot.
+func snapshotDir(lang, dir string, snapshot moduleSnapshot) error {
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if path != dir && IsBuildOutput(lang, d.Name(), d.IsDir()) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+
+			return nil
+		}
+
+		if d.IsDir() {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return errors.Wrapf(err, "failed to get Info for %s", path)
+		}
+
+		snapshot[path] = fmt.Sprintf("%d:%d", info.ModTime().UnixNano(), info.Size())
+
+		return nil
+	})
+
+	if err != nil {
+		return errors.Wrap(err, "failed to WalkDir")
+	}
+
+	return nil
+}
+
+func (m moduleSnapshot) equal(other moduleSnapshot) bool {
+	if len(m) != len(other) {
+		return false
+	}
+
+	for path, stamp := range m {
+		if other[path] != stamp {
+			return false
+		}
+	}
+
+	return true
+}
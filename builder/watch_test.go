@@ -0,0 +1,128 @@
+package builder
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/suborbital/subo/subo/util"
+)
+
+func TestWatcher_Watch(t *testing.T) {
+	runner := testCommands{}.runner()
+	b := testBuilder(runner, 1, "one", "two")
+
+	for i := range b.Context.Modules {
+		b.Context.Modules[i].Fullpath = t.TempDir()
+		require.NoError(t, os.WriteFile(filepath.Join(b.Context.Modules[i].Fullpath, "lib.wat"), []byte("(module)"), 0644))
+	}
+
+	w := NewWatcher(b, ToolchainNative)
+	w.Interval = 10 * time.Millisecond
+	w.Debounce = 50 * time.Millisecond
+
+	ctx, stop := context.WithCancel(context.Background())
+	built := make(chan struct{}, 1)
+	done := make(chan error)
+
+	go func() {
+		done <- w.Watch(ctx, func() error {
+			built <- struct{}{}
+			return nil
+		})
+	}()
+
+	// Give the watcher time to take its initial snapshot.
+	time.Sleep(50 * time.Millisecond)
+
+	twoPath := b.Context.Modules[1].Fullpath
+
+	// Build outputs must not trigger a rebuild.
+	require.NoError(t, os.WriteFile(filepath.Join(twoPath, "two.wasm"), []byte("wasm"), 0644))
+	require.NoError(t, os.MkdirAll(filepath.Join(twoPath, "target"), 0755))
+
+	require.NoError(t, os.WriteFile(filepath.Join(twoPath, "lib.wat"), []byte("(module (func))"), 0644))
+
+	select {
+	case <-built:
+	case <-time.After(5 * time.Second):
+		t.Fatal("module was not rebuilt")
+	}
+
+	stop()
+	assert.NoError(t, <-done)
+
+	// Only the changed module should have been built.
+	assert.Equal(t, []string{twoPath}, cmdDirs(runner))
+}
+
+func TestWatcher_Watch_EditedDuringBuild(t *testing.T) {
+	modPath := t.TempDir()
+	libPath := filepath.Join(modPath, "lib.wat")
+	require.NoError(t, os.WriteFile(libPath, []byte("(module)"), 0644))
+
+	// The first build is slow enough for the module to be edited again while it's running.
+	editing := make(chan struct{})
+	runner := &util.RecordingRunner{Handler: func(ctx context.Context, cmd util.Cmd) (*util.CmdResult, error) {
+		select {
+		case editing <- struct{}{}:
+			<-editing
+		default:
+		}
+
+		return testCommands{}.result(ctx, cmd)
+	}}
+
+	b := testBuilder(runner, 1, "one")
+	b.Context.Modules[0].Fullpath = modPath
+
+	w := NewWatcher(b, ToolchainNative)
+	w.Interval = 10 * time.Millisecond
+	w.Debounce = 50 * time.Millisecond
+
+	ctx, stop := context.WithCancel(context.Background())
+	built := make(chan struct{}, 10)
+	done := make(chan error)
+
+	go func() {
+		done <- w.Watch(ctx, func() error {
+			built <- struct{}{}
+			return nil
+		})
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+
+	require.NoError(t, os.WriteFile(libPath, []byte("(module (func))"), 0644))
+
+	select {
+	case <-editing:
+	case <-time.After(5 * time.Second):
+		t.Fatal("module was not rebuilt")
+	}
+
+	require.NoError(t, os.WriteFile(libPath, []byte("(module (func) (func))"), 0644))
+	editing <- struct{}{}
+
+	// The edit made during the first build must trigger a second one.
+	for i := 0; i < 2; i++ {
+		select {
+		case <-built:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("module was built %d times, expected 2", i)
+		}
+	}
+
+	time.Sleep(300 * time.Millisecond)
+
+	stop()
+	assert.NoError(t, <-done)
+
+	assert.Len(t, built, 0)
+	assert.Equal(t, []string{modPath, modPath}, cmdDirs(runner))
+}
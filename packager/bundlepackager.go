@@ -55,15 +55,21 @@ func (b *BundlePackageJob) Package(log util.FriendlyLogger, ctx *project.Context
 		ctx.TenantConfig.TenantVersion++
 	}
 
-	if err := project.WriteTenantConfig(ctx.Cwd, ctx.TenantConfig); err != nil {
-		return errors.Wrap(err, "failed to WriteTenantConfig")
+	if err := ctx.SaveTenantConfig(); err != nil {
+		return errors.Wrap(err, "failed to SaveTenantConfig")
 	}
 
-	if err := project.CalculateModuleRefs(ctx.TenantConfig, ctx.Modules); err != nil {
+	// The bundle's config has its secrets resolved, while tenant.json keeps the references to them.
+	config, err := ctx.ResolvedTenantConfig()
+	if err != nil {
+		return errors.Wrap(err, "🚫 failed to ResolvedTenantConfig")
+	}
+
+	if err := project.CalculateModuleRefs(config, ctx.Modules); err != nil {
 		return errors.Wrap(err, "🚫 failed to CalculateModuleRefs")
 	}
 
-	if err := ctx.TenantConfig.Validate(); err != nil {
+	if err := config.Validate(); err != nil {
 		return errors.Wrap(err, "🚫 failed to Validate Directive")
 	}
 
@@ -76,7 +82,7 @@ func (b *BundlePackageJob) Package(log util.FriendlyLogger, ctx *project.Context
 		log.LogInfo("adding static files to bundle")
 	}
 
-	configBytes, err := ctx.TenantConfig.Marshal()
+	configBytes, err := config.Marshal()
 	if err != nil {
 		return errors.Wrap(err, "failed to Directive.Marshal")
 	}
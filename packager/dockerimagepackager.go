@@ -44,8 +44,13 @@ func (b *DockerImagePackageJob) Package(log util.FriendlyLogger, ctx *project.Co
 		return errors.Wrap(err, "failed to dockerNameFromDirective")
 	}
 
-	if _, err := util.Command.Run(fmt.Sprintf("docker build . -t=%s", imageName)); err != nil {
-		return errors.Wrap(err, "🚫 failed to build Docker image")
+	engine, err := util.Engine()
+	if err != nil {
+		return errors.Wrap(err, "failed to util.Engine")
+	}
+
+	if _, err := util.Command.Run(engine.Command(fmt.Sprintf("build . -t=%s", imageName))); err != nil {
+		return errors.Wrapf(err, "🚫 failed to build image with %s", engine.Name)
 	}
 
 	util.LogDone(fmt.Sprintf("built Docker image -> %s", imageName))
@@ -0,0 +1,107 @@
+package project
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/suborbital/subo/subo/util"
+)
+
+// ModulesChangedSince returns the modules with files (or shared directories) that differ from the given git ref,
+// including uncommitted and untracked files, as well as any module that has not been built yet. Modules
+// that depend on a changed module are included too.
+func (b *Context) ModulesChangedSince(ref string) ([]ModuleDir, error) {
+	git := util.NewCommandLineExecutor(util.SilentOutput, nil)
+
+	topLevel, err := runGit(git, b.Cwd, "rev-parse", "--show-toplevel")
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to find git repository (is the project in a git repository?)")
+	}
+
+	root := resolvePath(strings.TrimSpace(topLevel))
+
+	// A ref starting with a dash would be taken as an option rather than a ref.
+	if strings.HasPrefix(ref, "-") {
+		return nil, fmt.Errorf("%s is not a valid git ref", ref)
+	}
+
+	if _, err := runGit(git, b.Cwd, "rev-parse", "--verify", "--quiet", ref+"^{commit}"); err != nil {
+		return nil, fmt.Errorf("%s is not a valid git ref", ref)
+	}
+
+	// Paths are NUL-terminated (-z) as otherwise git quotes those containing spaces or non-ASCII characters.
+	diff, err := runGit(git, root, "diff", "--name-only", "-z", ref, "--")
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to git diff %s", ref)
+	}
+
+	untracked, err := runGit(git, root, "ls-files", "--others", "--exclude-standard", "-z")
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to git ls-files")
+	}
+
+	changedFiles := []string{}
+	for _, file := range strings.Split(diff+untracked, "\x00") {
+		// Built modules are not sources, so an untracked .wasm file doesn't mean a module changed.
+		if file == "" || strings.HasSuffix(file, ".wasm") || strings.HasSuffix(file, ".wasm.zip") {
+			continue
+		}
+
+		changedFiles = append(changedFiles, filepath.Join(root, filepath.FromSlash(file)))
+	}
+
+	changed := []ModuleDir{}
+
+	for _, mod := range b.Modules {
+		if mod.HasWasmFile() != nil || containsPathUnder(changedFiles, resolvePath(mod.Fullpath)) {
+			changed = append(changed, mod)
+			continue
+		}
+
+		for _, shared := range mod.SharedDirPaths() {
+			if containsPathUnder(changedFiles, resolvePath(shared)) {
+				changed = append(changed, mod)
+				break
+			}
+		}
+	}
+
+	return b.WithDependents(changed), nil
+}
+
+// runGit runs git with the given arguments in dir, returning its output.
+func runGit(git util.CommandRunner, dir string, args ...string) (string, error) {
+	cmd := util.ArgsCmd(append([]string{"git"}, args...)...)
+	cmd.Dir = dir
+
+	result, err := git.Exec(context.Background(), cmd)
+	if err != nil {
+		return "", err
+	}
+
+	return result.Stdout, nil
+}
+
+// containsPathUnder returns true if any of the paths are within dir.
+func containsPathUnder(paths []string, dir string) bool {
+	for _, p := range paths {
+		if rel, err := filepath.Rel(dir, p); err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// resolvePath resolves symlinks in a path so that it can be compared with the paths reported by git.
+func resolvePath(path string) string {
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		return resolved
+	}
+
+	return path
+}
@@ -0,0 +1,72 @@
+package project
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestContext_ModulesChangedSince(t *testing.T) {
+	dir := t.TempDir()
+
+	git := func(args ...string) {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		cmd.Env = append(os.Environ(), "GIT_AUTHOR_NAME=test", "GIT_AUTHOR_EMAIL=test@example.com", "GIT_COMMITTER_NAME=test", "GIT_COMMITTER_EMAIL=test@example.com")
+
+		out, err := cmd.CombinedOutput()
+		require.NoError(t, err, string(out))
+	}
+
+	for _, name := range []string{"one", "two", "three", "four", "five"} {
+		require.NoError(t, os.MkdirAll(filepath.Join(dir, name), 0755))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name, ".module.yaml"), []byte("lang: wat\n"), 0644))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name, "lib.wat"), []byte("(module)"), 0644))
+	}
+
+	// git quotes paths with spaces or non-ASCII characters unless they're NUL-terminated.
+	quotedPath := filepath.Join(dir, "four", "données de test.wat")
+	require.NoError(t, os.WriteFile(quotedPath, []byte("(module)"), 0644))
+
+	git("init", "-q")
+	git("add", "-A")
+	git("commit", "-q", "-m", "initial")
+
+	// Every module apart from three has been built.
+	for _, name := range []string{"one", "two", "four", "five"} {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name, name+".wasm"), []byte("wasm"), 0644))
+	}
+
+	// two and four have changed since the initial commit, and five has a new file.
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "two", "lib.wat"), []byte("(module (func))"), 0644))
+	require.NoError(t, os.WriteFile(quotedPath, []byte("(module (func))"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "five", "ünïcode ñame.wat"), []byte("(module)"), 0644))
+
+	ctx, err := ForDirectory(dir)
+	require.NoError(t, err)
+
+	changed, err := ctx.ModulesChangedSince("HEAD")
+	require.NoError(t, err)
+
+	names := []string{}
+	for _, m := range changed {
+		names = append(names, m.Name)
+	}
+
+	assert.ElementsMatch(t, []string{"two", "three", "four", "five"}, names)
+
+	_, err = ctx.ModulesChangedSince("not-a-ref")
+	assert.Error(t, err)
+
+	// Refs are passed to git as arguments, never interpreted by a shell or as options.
+	for _, ref := range []string{"HEAD; touch pwned", "--output=pwned"} {
+		_, err = ctx.ModulesChangedSince(ref)
+		assert.Error(t, err)
+	}
+
+	assert.NoFileExists(t, filepath.Join(dir, "pwned"))
+}
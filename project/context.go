@@ -12,34 +12,29 @@ import (
 	"gopkg.in/yaml.v2"
 
 	"github.com/suborbital/subo/subo/release"
-	"github.com/suborbital/subo/subo/util"
 	"github.com/suborbital/systemspec/tenant"
 )
 
-// validLangs are the available languages.
-var validLangs = map[string]struct{}{
-	"rust":           {},
-	"swift":          {},
-	"assemblyscript": {},
-	"tinygo":         {},
-	"grain":          {},
-	"typescript":     {},
-	"javascript":     {},
-	"wat":            {},
-}
-
 // Context describes the context under which the tool is being run.
 type Context struct {
 	Cwd            string
 	CwdIsModule    bool
+	Project        *ProjectFile
 	Modules        []ModuleDir
 	Bundle         BundleRef
 	TenantConfig   *tenant.Config
+	Env            string
 	RuntimeVersion string
 	Langs          []string
 	MountPath      string
 	RelDockerPath  string
 	BuilderTag     string
+	// Defaults are the defaults for commands' settings from the environment, the subo.yaml at the project's
+	// root (which may be a parent of Cwd) and the user's config file.
+	Defaults *Defaults
+
+	// secrets resolves the references in TenantConfig's connections, created when it's first needed.
+	secrets *secretResolver
 }
 
 // ModuleDir represents a directory containing a module.
@@ -48,9 +43,27 @@ type ModuleDir struct {
 	UnderscoreName string
 	Fullpath       string
 	Module         *tenant.Module
+	Config         ModuleConfig
 	CompilerFlags  string
 }
 
+// ModuleConfig holds the subo-specific settings from a module's .module.yaml which are not part of tenant.Module.
+type ModuleConfig struct {
+	// PreBuild commands are run before the module's build commands.
+	PreBuild []string `yaml:"preBuild,omitempty"`
+	// Build commands replace the language's native build commands if set.
+	Build []string `yaml:"build,omitempty"`
+	// PostBuild commands are run after the module's build commands.
+	PostBuild []string `yaml:"postBuild,omitempty"`
+	// Optimize strips custom sections from the module after it is built.
+	Optimize bool `yaml:"optimize,omitempty"`
+	// DependsOn are the names of modules in the same namespace which must be built before this one.
+	DependsOn []string `yaml:"dependsOn,omitempty"`
+	// SharedDirs are directories outside the module (relative to it) that its build uses, such as local
+	// libraries. The module is rebuilt when files within them change.
+	SharedDirs []string `yaml:"sharedDirs,omitempty"`
+}
+
 // BundleRef contains information about a bundle in the current context.
 type BundleRef struct {
 	Exists   bool
@@ -59,45 +72,100 @@ type BundleRef struct {
 
 // ForDirectory returns the build context for the provided working directory.
 func ForDirectory(dir string) (*Context, error) {
+	bctx, errs, err := loadContext(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(errs) > 0 {
+		return nil, errs[0]
+	}
+
+	return bctx, nil
+}
+
+// loadContext loads the build context for a directory, carrying on past problems with the project's files so that
+// every one of them is returned rather than just the first. ForDirectory fails on the first of them, while Validate
+// reports them all. An error is only returned if the directory can't be loaded at all.
+func loadContext(dir string) (*Context, []error, error) {
 	fullDir, err := filepath.Abs(dir)
 	if err != nil {
-		return nil, errors.Wrap(err, "failed to get Abs path")
+		return nil, nil, errors.Wrap(err, "failed to get Abs path")
 	}
 
-	modules, cwdIsModule, err := getModuleDirs(fullDir)
+	errs := []error{}
+
+	// Commands run within a project (such as in a module's directory) use the subo.yaml and Languages.yaml at its root.
+	root, err := FindProjectRoot(fullDir)
 	if err != nil {
-		return nil, errors.Wrap(err, "failed to getModuleDirs")
+		return nil, nil, errors.Wrap(err, "failed to FindProjectRoot")
 	}
 
-	bundle, err := bundleTargetPath(fullDir)
+	// Custom languages must be registered before the modules using them are loaded.
+	if err := loadLanguagesFile(root); err != nil {
+		file, _ := filepath.Rel(fullDir, filepath.Join(root, "Languages.yaml"))
+		errs = append(errs, errors.Wrap(&FileError{File: file, Err: err}, "failed to loadLanguagesFile"))
+	}
+
+	projectFile, err := ReadProjectFile(root)
 	if err != nil {
-		return nil, errors.Wrap(err, "failed to bundleIfExists")
+		file, _ := filepath.Rel(fullDir, filepath.Join(root, ProjectFileName))
+		errs = append(errs, errors.Wrap(&FileError{File: file, Err: err}, "failed to ReadProjectFile"))
+		projectFile = &ProjectFile{}
 	}
 
-	config, err := readTenantConfig(fullDir)
+	userConfig, err := ReadUserConfig()
 	if err != nil {
-		if !os.IsNotExist(errors.Cause(err)) {
-			return nil, errors.Wrap(err, "failed to readDirectiveFile")
-		}
+		errs = append(errs, errors.Wrap(err, "failed to ReadUserConfig"))
+		userConfig = &ProjectFile{}
+	}
+
+	// The discovery paths are relative to the root, so they're only used when loading the whole project.
+	discovery := DiscoveryConfig{}
+	if root == fullDir {
+		discovery = projectFile.Modules
 	}
 
-	queries, err := readQueriesFile(dir)
+	// Modules is left nil if they couldn't be found, rather than empty.
+	modules, cwdIsModule, err := findModuleDirs(fullDir, discovery)
 	if err != nil {
-		return nil, errors.Wrap(err, "failed to readQueriesFile")
-	} else if len(queries) > 0 {
-		config.DefaultNamespace.Queries = queries
+		errs = append(errs, errors.Wrap(err, "failed to getModuleDirs"))
+	} else if modules == nil {
+		modules = []ModuleDir{}
+	}
+
+	for _, m := range modules {
+		if !IsValidLang(m.Module.Lang) {
+			errs = append(errs, errors.Wrap(&LangError{Module: m}, "failed to getModuleDirs"))
+		}
 	}
 
-	connections, err := readConnectionsFile(dir)
+	// A single module's dependencies are outside of the context, so they're only checked for projects.
+	if !cwdIsModule {
+		for _, err := range validateDependencies(modules) {
+			errs = append(errs, errors.Wrap(err, "failed to validateDependencies"))
+		}
+	}
+
+	bundle, err := bundleTargetPath(fullDir)
 	if err != nil {
-		return nil, errors.Wrap(err, "failed to readConnectionsFile")
-	} else if len(connections) > 0 {
-		config.DefaultNamespace.Connections = connections
+		return nil, nil, errors.Wrap(err, "failed to bundleIfExists")
+	}
+
+	// Queries.yaml and Connections.yaml are only merged into a tenant.json that could be read.
+	config, err := readTenantConfig(fullDir)
+	if err != nil && !os.IsNotExist(errors.Cause(err)) {
+		errs = append(errs, errors.Wrap(&FileError{File: "tenant.json", Err: err}, "failed to readDirectiveFile"))
+	} else {
+		for _, err := range applyNamespaceFiles(fullDir, config) {
+			errs = append(errs, errors.Wrap(err, "failed to applyNamespaceFiles"))
+		}
 	}
 
 	bctx := &Context{
 		Cwd:           fullDir,
 		CwdIsModule:   cwdIsModule,
+		Project:       projectFile,
 		Modules:       modules,
 		Bundle:        *bundle,
 		TenantConfig:  config,
@@ -105,9 +173,10 @@ func ForDirectory(dir string) (*Context, error) {
 		MountPath:     fullDir,
 		RelDockerPath: ".",
 		BuilderTag:    fmt.Sprintf("v%s", release.SuboVersion),
+		Defaults:      &Defaults{project: projectFile, user: userConfig},
 	}
 
-	return bctx, nil
+	return bctx, errs, nil
 }
 
 // ModuleExists returns true if the context contains a module with name <name>.
@@ -187,9 +256,8 @@ func (m *ModuleDir) HasWasmFile() error {
 	return nil
 }
 
-func getModuleDirs(cwd string) ([]ModuleDir, bool, error) {
-	modules := []ModuleDir{}
-
+// findModuleDirs finds the module in cwd, or the modules within it if it isn't one, without checking their languages.
+func findModuleDirs(cwd string, config DiscoveryConfig) ([]ModuleDir, bool, error) {
 	// Go through all of the dirs in the current dir.
 	topLvlFiles, err := ioutil.ReadDir(cwd)
 	if err != nil {
@@ -202,32 +270,12 @@ func getModuleDirs(cwd string) ([]ModuleDir, bool, error) {
 	if err != nil {
 		return nil, false, errors.Wrap(err, "failed to getModuleFromFiles")
 	} else if moduleDir != nil {
-		modules = append(modules, *moduleDir)
-		return modules, true, nil
+		return []ModuleDir{*moduleDir}, true, nil
 	}
 
-	for _, tf := range topLvlFiles {
-		if !tf.IsDir() {
-			continue
-		}
-
-		dirPath := filepath.Join(cwd, tf.Name())
-
-		// Determine if a .module file exists in that dir.
-		innerFiles, err := ioutil.ReadDir(dirPath)
-		if err != nil {
-			util.LogWarn(fmt.Sprintf("couldn't read files in %v", dirPath))
-			continue
-		}
-
-		moduleDir, err := getModuleFromFiles(dirPath, innerFiles)
-		if err != nil {
-			return nil, false, errors.Wrap(err, "failed to getModuleFromFiles")
-		} else if moduleDir == nil {
-			continue
-		}
-
-		modules = append(modules, *moduleDir)
+	modules, err := discoverModules(cwd, config)
+	if err != nil {
+		return nil, false, errors.Wrap(err, "failed to discoverModules")
 	}
 
 	return modules, false, nil
@@ -244,11 +292,11 @@ func ContainsModuleYaml(files []os.FileInfo) (string, bool) {
 	return "", false
 }
 
-// IsValidLang returns true if a language is valid.
+// IsValidLang returns true if a language is registered under the given name (aliases are not valid).
 func IsValidLang(lang string) bool {
-	_, exists := validLangs[lang]
+	l, exists := LanguageFor(lang)
 
-	return exists
+	return exists && l.Name == lang
 }
 
 func getModuleFromFiles(wd string, files []os.FileInfo) (*ModuleDir, error) {
@@ -264,7 +312,12 @@ func getModuleFromFiles(wd string, files []os.FileInfo) (*ModuleDir, error) {
 
 	module := &tenant.Module{}
 	if err := yaml.Unmarshal(moduleBytes, &module); err != nil {
-		return nil, errors.Wrap(err, "failed to Unmarshal .module yaml")
+		return nil, errors.Wrapf(err, "failed to Unmarshal %s", filepath.Join(wd, filename))
+	}
+
+	config := ModuleConfig{}
+	if err := yaml.Unmarshal(moduleBytes, &config); err != nil {
+		return nil, errors.Wrap(err, "failed to Unmarshal .module yaml config")
 	}
 
 	if module.Name == "" {
@@ -275,10 +328,6 @@ func getModuleFromFiles(wd string, files []os.FileInfo) (*ModuleDir, error) {
 		module.Namespace = "default"
 	}
 
-	if ok := IsValidLang(module.Lang); !ok {
-		return nil, fmt.Errorf("(%s) %s is not a valid lang", module.Name, module.Lang)
-	}
-
 	absolutePath, err := filepath.Abs(wd)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to get Abs filepath")
@@ -289,6 +338,7 @@ func getModuleFromFiles(wd string, files []os.FileInfo) (*ModuleDir, error) {
 		UnderscoreName: strings.Replace(module.Name, "-", "_", -1),
 		Fullpath:       absolutePath,
 		Module:         module,
+		Config:         config,
 	}
 
 	return moduleDir, nil
@@ -0,0 +1,177 @@
+package project
+
+import (
+	"path/filepath"
+)
+
+// SharedDirPaths returns the absolute paths of the shared directories the module's build uses.
+func (m *ModuleDir) SharedDirPaths() []string {
+	paths := []string{}
+
+	for _, dir := range m.Config.SharedDirs {
+		if filepath.IsAbs(dir) {
+			paths = append(paths, filepath.Clean(dir))
+		} else {
+			paths = append(paths, filepath.Join(m.Fullpath, dir))
+		}
+	}
+
+	return paths
+}
+
+// Key identifies the module within its project, as modules in different namespaces can have the same name.
+func (m *ModuleDir) Key() string {
+	return moduleKey(m.namespace(), m.Name)
+}
+
+// DependencyKeys returns the keys of the modules the module depends on, which are in its own namespace.
+func (m *ModuleDir) DependencyKeys() []string {
+	keys := make([]string, len(m.Config.DependsOn))
+	for i, dep := range m.Config.DependsOn {
+		keys[i] = moduleKey(m.namespace(), dep)
+	}
+
+	return keys
+}
+
+func (m *ModuleDir) namespace() string {
+	if m.Module == nil {
+		return ""
+	}
+
+	return m.Module.Namespace
+}
+
+func moduleKey(namespace, name string) string {
+	return namespace + "/" + name
+}
+
+// ModuleByKey returns the module in the context with the given key (see ModuleDir.Key).
+func (b *Context) ModuleByKey(key string) (ModuleDir, bool) {
+	for _, m := range b.Modules {
+		if m.Key() == key {
+			return m, true
+		}
+	}
+
+	return ModuleDir{}, false
+}
+
+// WithDependents returns the given modules along with every module in the context that depends on
+// any of them (directly or transitively), in the same order as the context's modules.
+func (b *Context) WithDependents(mods []ModuleDir) []ModuleDir {
+	included := map[string]bool{}
+	for _, m := range mods {
+		included[m.Key()] = true
+	}
+
+	// Keep passing over the modules until no more dependents are found, which handles transitive dependencies.
+	for added := true; added; {
+		added = false
+
+		for _, m := range b.Modules {
+			if included[m.Key()] {
+				continue
+			}
+
+			for _, dep := range m.DependencyKeys() {
+				if included[dep] {
+					included[m.Key()] = true
+					added = true
+
+					break
+				}
+			}
+		}
+	}
+
+	withDependents := []ModuleDir{}
+
+	for _, m := range b.Modules {
+		if included[m.Key()] {
+			withDependents = append(withDependents, m)
+		}
+	}
+
+	return withDependents
+}
+
+// SortModules orders modules so that each comes after the modules it depends on. Modules are otherwise
+// kept in their original order, and dependencies that aren't in the list are assumed to be built already.
+func SortModules(mods []ModuleDir) ([]ModuleDir, error) {
+	indices := map[string]int{}
+	for i, m := range mods {
+		indices[m.Key()] = i
+	}
+
+	sorted := make([]ModuleDir, 0, len(mods))
+
+	// 0 is unvisited, 1 is in progress (used to detect cycles) and 2 is done.
+	state := make([]int, len(mods))
+	path := []string{}
+
+	var visit func(i int) error
+
+	visit = func(i int) error {
+		switch state[i] {
+		case 1:
+			return &CycleError{Path: append(append([]string{}, path...), mods[i].Name)}
+		case 2:
+			return nil
+		}
+
+		state[i] = 1
+		path = append(path, mods[i].Name)
+
+		for _, dep := range mods[i].DependencyKeys() {
+			if j, exists := indices[dep]; exists {
+				if err := visit(j); err != nil {
+					return err
+				}
+			}
+		}
+
+		path = path[:len(path)-1]
+		state[i] = 2
+		sorted = append(sorted, mods[i])
+
+		return nil
+	}
+
+	for i := range mods {
+		if err := visit(i); err != nil {
+			return nil, err
+		}
+	}
+
+	return sorted, nil
+}
+
+// validateDependencies checks that every module dependency exists and that there are no cycles, returning an error
+// for each dependency that doesn't exist. Cycles are only looked for once every dependency exists.
+func validateDependencies(mods []ModuleDir) []error {
+	keys := map[string]bool{}
+	for _, m := range mods {
+		keys[m.Key()] = true
+	}
+
+	errs := []error{}
+
+	for _, m := range mods {
+		for i, dep := range m.Config.DependsOn {
+			if dep == m.Name || !keys[m.DependencyKeys()[i]] {
+				errs = append(errs, &DependencyError{Module: m, Dependency: dep})
+			}
+		}
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+
+	if _, err := SortModules(mods); err != nil {
+		return []error{err}
+	}
+
+	return nil
+}
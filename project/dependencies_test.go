@@ -0,0 +1,112 @@
+package project
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/suborbital/systemspec/tenant"
+)
+
+func testModule(name string, dependsOn ...string) ModuleDir {
+	return ModuleDir{Name: name, Config: ModuleConfig{DependsOn: dependsOn}}
+}
+
+func moduleNames(mods []ModuleDir) []string {
+	names := []string{}
+	for _, m := range mods {
+		names = append(names, m.Name)
+	}
+
+	return names
+}
+
+func TestSortModules(t *testing.T) {
+	mods := []ModuleDir{
+		testModule("app", "lib", "utils"),
+		testModule("other"),
+		testModule("lib", "utils"),
+		testModule("utils"),
+	}
+
+	sorted, err := SortModules(mods)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"utils", "lib", "app", "other"}, moduleNames(sorted))
+
+	// Dependencies that aren't being built don't affect the order.
+	sorted, err = SortModules([]ModuleDir{testModule("app", "lib"), testModule("other")})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"app", "other"}, moduleNames(sorted))
+
+	_, err = SortModules([]ModuleDir{testModule("a", "b"), testModule("b", "c"), testModule("c", "a")})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "a -> b -> c -> a")
+	}
+}
+
+func TestValidateDependencies(t *testing.T) {
+	assert.Empty(t, validateDependencies([]ModuleDir{testModule("app", "lib"), testModule("lib")}))
+	assert.EqualError(t, validateDependencies([]ModuleDir{testModule("app", "app")})[0], "module app depends on itself")
+
+	errs := validateDependencies([]ModuleDir{testModule("app", "missing", "gone")})
+	if assert.Len(t, errs, 2) {
+		assert.EqualError(t, errs[0], "module app depends on missing, which does not exist")
+		assert.EqualError(t, errs[1], "module app depends on gone, which does not exist")
+	}
+
+	errs = validateDependencies([]ModuleDir{testModule("a", "b"), testModule("b", "a")})
+	if assert.Len(t, errs, 1) {
+		assert.IsType(t, &CycleError{}, errs[0])
+	}
+
+	// Dependencies are looked for in the module's own namespace.
+	admin := testModule("app", "lib")
+	admin.Module = &tenant.Module{Name: "app", Namespace: "admin"}
+
+	errs = validateDependencies([]ModuleDir{admin, testModule("lib")})
+	if assert.Len(t, errs, 1) {
+		assert.EqualError(t, errs[0], "module app depends on lib, which does not exist")
+	}
+}
+
+func TestContext_WithDependents(t *testing.T) {
+	ctx := &Context{
+		Modules: []ModuleDir{
+			testModule("app", "lib"),
+			testModule("other"),
+			testModule("lib", "utils"),
+			testModule("utils"),
+		},
+	}
+
+	assert.Equal(t, []string{"app", "lib", "utils"}, moduleNames(ctx.WithDependents([]ModuleDir{testModule("utils")})))
+	assert.Equal(t, []string{"other"}, moduleNames(ctx.WithDependents([]ModuleDir{testModule("other")})))
+}
+
+func TestForDirectory_Dependencies(t *testing.T) {
+	dir := t.TempDir()
+
+	writeModule := func(name, extra string) {
+		require.NoError(t, os.MkdirAll(filepath.Join(dir, name), 0755))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name, ".module.yaml"), []byte("name: "+name+"\nlang: wat\n"+extra), 0644))
+	}
+
+	writeModule("app", "dependsOn: [lib]\nsharedDirs: [../shared]\n")
+	writeModule("lib", "")
+
+	ctx, err := ForDirectory(dir)
+	require.NoError(t, err)
+
+	app, exists := ctx.ModuleByKey("default/app")
+	require.True(t, exists)
+	assert.Equal(t, []string{"lib"}, app.Config.DependsOn)
+	assert.Equal(t, []string{filepath.Join(dir, "shared")}, app.SharedDirPaths())
+
+	writeModule("lib", "dependsOn: [app]\n")
+
+	_, err = ForDirectory(dir)
+	assert.Error(t, err)
+}
@@ -0,0 +1,179 @@
+package project
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/suborbital/subo/subo/util"
+	"github.com/suborbital/systemspec/fqmn"
+)
+
+// defaultDiscoveryDepth is how many directories deep a project is searched for modules by default.
+const defaultDiscoveryDepth = 3
+
+// defaultDiscoveryIgnore are directories that never contain modules, or that are too large to search.
+var defaultDiscoveryIgnore = []string{".*", "node_modules", "target", "vendor", "_lib"}
+
+// discoverModules finds the modules within a project, either from the explicit list of paths
+// in the discovery config or by searching the project's directories. Directories containing a
+// module are not searched any further, and modules with the same name are reported as an error.
+func discoverModules(cwd string, config DiscoveryConfig) ([]ModuleDir, error) {
+	modules := []ModuleDir{}
+
+	if len(config.Paths) > 0 {
+		for _, p := range config.Paths {
+			dirPath := filepath.Join(cwd, p)
+
+			moduleDir, err := moduleInDir(dirPath)
+			if err != nil {
+				return nil, errors.Wrapf(err, "failed to load module at %s", p)
+			} else if moduleDir == nil {
+				return nil, fmt.Errorf("%s is listed in %s but contains no .module.yaml", p, ProjectFileName)
+			}
+
+			modules = append(modules, *moduleDir)
+		}
+	} else {
+		maxDepth := config.MaxDepth
+		if maxDepth < 1 {
+			maxDepth = defaultDiscoveryDepth
+		}
+
+		ignore := append(append([]string{}, defaultDiscoveryIgnore...), config.Ignore...)
+
+		if err := searchForModules(cwd, cwd, 1, maxDepth, ignore, &modules); err != nil {
+			return nil, errors.Wrap(err, "failed to searchForModules")
+		}
+	}
+
+	if err := checkDuplicateModules(cwd, modules); err != nil {
+		return nil, err
+	}
+
+	return modules, nil
+}
+
+// searchForModules searches the subdirectories of dir (which is depth levels below the project root) for modules.
+func searchForModules(cwd, dir string, depth, maxDepth int, ignore []string, modules *[]ModuleDir) error {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return errors.Wrapf(err, "failed to list directory %s", dir)
+	}
+
+	for _, f := range files {
+		if !f.IsDir() {
+			continue
+		}
+
+		dirPath := filepath.Join(dir, f.Name())
+
+		if isIgnoredDir(cwd, dirPath, ignore) {
+			continue
+		}
+
+		// Determine if a .module file exists in that dir.
+		innerFiles, err := ioutil.ReadDir(dirPath)
+		if err != nil {
+			// Warnings go to stderr, as stdout may be a machine-readable build report.
+			(&util.StderrLogger{}).LogWarn(fmt.Sprintf("couldn't read files in %v", dirPath))
+			continue
+		}
+
+		moduleDir, err := getModuleFromFiles(dirPath, innerFiles)
+		if err != nil {
+			return errors.Wrap(err, "failed to getModuleFromFiles")
+		} else if moduleDir != nil {
+			*modules = append(*modules, *moduleDir)
+			continue
+		}
+
+		if depth < maxDepth {
+			if err := searchForModules(cwd, dirPath, depth+1, maxDepth, ignore, modules); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// moduleInDir returns the module in dir, or nil if it doesn't contain one.
+func moduleInDir(dir string) (*ModuleDir, error) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list directory")
+	}
+
+	return getModuleFromFiles(dir, files)
+}
+
+// isIgnoredDir returns true if the directory's name or path relative to the project matches any of the ignore globs.
+func isIgnoredDir(cwd, dir string, ignore []string) bool {
+	rel, err := filepath.Rel(cwd, dir)
+	if err != nil {
+		rel = dir
+	}
+
+	rel = filepath.ToSlash(rel)
+	name := filepath.Base(dir)
+
+	for _, pattern := range ignore {
+		pattern = strings.TrimSuffix(filepath.ToSlash(pattern), "/")
+
+		if matched, _ := filepath.Match(pattern, name); matched {
+			return true
+		}
+
+		if matched, _ := filepath.Match(pattern, rel); matched {
+			return true
+		}
+	}
+
+	return false
+}
+
+// checkDuplicateModules returns an error listing every module name that is used by more than one module in the same
+// namespace. Modules in other namespaces are listed with their namespace, e.g. admin/hello.
+func checkDuplicateModules(cwd string, modules []ModuleDir) error {
+	paths := map[string][]string{}
+	names := map[string]string{}
+	keys := []string{}
+
+	for _, m := range modules {
+		rel, err := filepath.Rel(cwd, m.Fullpath)
+		if err != nil {
+			rel = m.Fullpath
+		}
+
+		key := m.Key()
+
+		if _, exists := paths[key]; !exists {
+			keys = append(keys, key)
+
+			names[key] = m.Name
+			if ns := m.namespace(); ns != "" && ns != fqmn.NamespaceDefault {
+				names[key] = fmt.Sprintf("%s/%s", ns, m.Name)
+			}
+		}
+
+		paths[key] = append(paths[key], rel)
+	}
+
+	duplicates := []string{}
+
+	for _, key := range keys {
+		if len(paths[key]) > 1 {
+			duplicates = append(duplicates, fmt.Sprintf("%s (%s)", names[key], strings.Join(paths[key], ", ")))
+		}
+	}
+
+	if len(duplicates) > 0 {
+		return fmt.Errorf("duplicate module names: %s", strings.Join(duplicates, "; "))
+	}
+
+	return nil
+}
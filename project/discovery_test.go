@@ -0,0 +1,115 @@
+package project
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeTestModule writes a wat module's .module.yaml at path (relative to dir).
+func writeTestModule(t *testing.T, dir, path, name string) {
+	t.Helper()
+
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, path), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, path, ".module.yaml"), []byte("name: "+name+"\nlang: wat\n"), 0644))
+}
+
+func TestForDirectory_Discovery(t *testing.T) {
+	dir := t.TempDir()
+
+	writeTestModule(t, dir, "flat", "flat")
+	writeTestModule(t, dir, "services/billing/charge", "charge")
+	writeTestModule(t, dir, "services/users", "users")
+	writeTestModule(t, dir, "services/users/nested", "nested") // modules don't contain other modules.
+	writeTestModule(t, dir, "a/b/c/d/too-deep", "too-deep")
+	writeTestModule(t, dir, "web/node_modules/dep", "dep")
+	writeTestModule(t, dir, ".git/hooks", "hooks")
+	writeTestModule(t, dir, "experiments/scratch", "scratch")
+
+	t.Run("searches recursively with default ignores", func(t *testing.T) {
+		ctx, err := ForDirectory(dir)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"scratch", "flat", "charge", "users"}, moduleNames(ctx.Modules))
+	})
+
+	t.Run("uses max depth and ignore globs from subo.yaml", func(t *testing.T) {
+		projectYaml := "modules:\n  maxDepth: 5\n  ignore:\n    - experiments\n    - services/billing\n"
+		require.NoError(t, os.WriteFile(filepath.Join(dir, ProjectFileName), []byte(projectYaml), 0644))
+		defer os.Remove(filepath.Join(dir, ProjectFileName))
+
+		ctx, err := ForDirectory(dir)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"too-deep", "flat", "users"}, moduleNames(ctx.Modules))
+	})
+
+	t.Run("uses the explicit module list from subo.yaml", func(t *testing.T) {
+		projectYaml := "modules:\n  paths:\n    - services/users\n    - flat\n"
+		require.NoError(t, os.WriteFile(filepath.Join(dir, ProjectFileName), []byte(projectYaml), 0644))
+		defer os.Remove(filepath.Join(dir, ProjectFileName))
+
+		ctx, err := ForDirectory(dir)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"users", "flat"}, moduleNames(ctx.Modules))
+	})
+
+	t.Run("reports listed paths without modules", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, ProjectFileName), []byte("modules:\n  paths: [services]\n"), 0644))
+		defer os.Remove(filepath.Join(dir, ProjectFileName))
+
+		_, err := ForDirectory(dir)
+		assert.Error(t, err)
+	})
+
+	t.Run("loads settings from the project root within a module", func(t *testing.T) {
+		t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+
+		projectYaml := "modules:\n  paths: [services]\nbuild:\n  builderTag: v0.6.0\n"
+		require.NoError(t, os.WriteFile(filepath.Join(dir, ProjectFileName), []byte(projectYaml), 0644))
+		defer os.Remove(filepath.Join(dir, ProjectFileName))
+
+		ctx, err := ForDirectory(filepath.Join(dir, "flat"))
+		require.NoError(t, err)
+		assert.Equal(t, []string{"flat"}, moduleNames(ctx.Modules))
+
+		tag, exists := ctx.Defaults.Value("build", "builder-tag")
+		assert.True(t, exists)
+		assert.Equal(t, "v0.6.0", tag)
+	})
+}
+
+func TestForDirectory_DuplicateModules(t *testing.T) {
+	dir := t.TempDir()
+
+	writeTestModule(t, dir, "one/hello", "hello")
+	writeTestModule(t, dir, "two/hello", "hello")
+	writeTestModule(t, dir, "goodbye", "goodbye")
+
+	_, err := ForDirectory(dir)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "duplicate module names: hello (one/hello, two/hello)")
+	}
+}
+
+func TestForDirectory_DuplicateModulesInNamespaces(t *testing.T) {
+	dir := t.TempDir()
+
+	writeTestModule(t, dir, "one/hello", "hello")
+	writeTestModule(t, dir, "admin/hello", "hello")
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "admin/hello/.module.yaml"), []byte("name: hello\nlang: wat\nnamespace: admin\n"), 0644))
+
+	// Modules with the same name in different namespaces have different FQMNs.
+	ctx, err := ForDirectory(dir)
+	require.NoError(t, err)
+	assert.Len(t, ctx.Modules, 2)
+
+	writeTestModule(t, dir, "two/hello", "hello")
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "two/hello/.module.yaml"), []byte("name: hello\nlang: wat\nnamespace: admin\n"), 0644))
+
+	_, err = ForDirectory(dir)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "duplicate module names: admin/hello (admin/hello, two/hello)")
+	}
+}
@@ -0,0 +1,69 @@
+package project
+
+import (
+	"fmt"
+	"strings"
+)
+
+// FileError is an error reading or parsing one of the project's files.
+type FileError struct {
+	// File is the path of the file relative to the project.
+	File string
+	Err  error
+}
+
+func (e *FileError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *FileError) Unwrap() error {
+	return e.Err
+}
+
+// LangError is a module whose lang isn't registered, or is an alias of one that is.
+type LangError struct {
+	Module ModuleDir
+}
+
+func (e *LangError) Error() string {
+	return fmt.Sprintf("(%s) %s is not a valid lang", e.Module.Name, e.Module.Module.Lang)
+}
+
+// DependencyError is a module that depends on itself or on a module that doesn't exist.
+type DependencyError struct {
+	Module     ModuleDir
+	Dependency string
+}
+
+func (e *DependencyError) Error() string {
+	if e.Dependency == e.Module.Name {
+		return fmt.Sprintf("module %s depends on itself", e.Module.Name)
+	}
+
+	return fmt.Sprintf("module %s depends on %s, which does not exist", e.Module.Name, e.Dependency)
+}
+
+// CycleError is a chain of modules that depend on each other.
+type CycleError struct {
+	// Path is the names of the modules in the cycle, starting and ending with the same one.
+	Path []string
+}
+
+func (e *CycleError) Error() string {
+	return fmt.Sprintf("dependency cycle: %s", strings.Join(e.Path, " -> "))
+}
+
+// NamespaceFileError is a Queries.yaml or Connections.yaml that can't be merged into the tenant config.
+type NamespaceFileError struct {
+	File string
+	// Missing are the namespaces in the file that tenant.json doesn't have, or nil if there is no tenant.json.
+	Missing []string
+}
+
+func (e *NamespaceFileError) Error() string {
+	if e.Missing == nil {
+		return fmt.Sprintf("%s can't be used without a tenant.json", e.File)
+	}
+
+	return fmt.Sprintf("%s refers to namespaces that don't exist in tenant.json: %s", e.File, strings.Join(e.Missing, ", "))
+}
@@ -0,0 +1,272 @@
+package project
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/suborbital/systemspec/fqmn"
+	"github.com/suborbital/systemspec/tenant"
+	"github.com/suborbital/systemspec/tenant/executable"
+)
+
+// GraphFormatDOT and others are the formats that workflow graphs can be written in.
+const (
+	GraphFormatDOT     = "dot"
+	GraphFormatMermaid = "mermaid"
+)
+
+// GraphFormats are the formats that workflow graphs can be written in.
+var GraphFormats = []string{GraphFormatDOT, GraphFormatMermaid}
+
+// graphWorkflow is a workflow's steps laid out as nodes and the edges between them.
+type graphWorkflow struct {
+	id        string
+	namespace string
+	name      string
+	nodes     []graphNode
+	edges     [][2]string
+}
+
+type graphNode struct {
+	id    string
+	label []string
+	// trigger nodes are the sources of the messages that start the workflow.
+	trigger bool
+	// missing nodes are modules that are referenced by the workflow but aren't in the project.
+	missing bool
+}
+
+// WriteWorkflowGraph writes a graph of the tenant config's workflows (only those named, if any are) in the given format.
+// Each step's module is annotated with its language and namespace, and modules that aren't in the project are highlighted.
+func (b *Context) WriteWorkflowGraph(w io.Writer, format, namespace string, names []string) error {
+	if b.TenantConfig == nil {
+		return errors.New("no tenant.json found")
+	}
+
+	workflows, err := b.workflowGraphs(namespace, names)
+	if err != nil {
+		return err
+	}
+
+	var out string
+
+	switch format {
+	case GraphFormatDOT:
+		out = renderDOT(workflows)
+	case GraphFormatMermaid:
+		out = renderMermaid(workflows)
+	default:
+		return fmt.Errorf("%s is not a valid graph format (must be one of %s)", format, strings.Join(GraphFormats, ", "))
+	}
+
+	if _, err := io.WriteString(w, out); err != nil {
+		return errors.Wrap(err, "failed to WriteString")
+	}
+
+	return nil
+}
+
+// workflowGraphs lays out the workflows in the namespace (or all namespaces if it's empty) with the given names (or all of them).
+func (b *Context) workflowGraphs(namespace string, names []string) ([]graphWorkflow, error) {
+	// find the project's module for each FQMN referenced in a workflow, leaving those that aren't in the project nil.
+	modules := map[string]*ModuleDir{}
+
+	for _, modFQMN := range getWorkflowFQMNList(b.TenantConfig) {
+		modules[modFQMN] = nil
+
+		FQMN, err := fqmn.Parse(modFQMN)
+		if err != nil {
+			continue
+		}
+
+		for i, m := range b.Modules {
+			if m.Name == FQMN.Name && m.Module.Namespace == FQMN.Namespace {
+				modules[modFQMN] = &b.Modules[i]
+				break
+			}
+		}
+	}
+
+	found := map[string]bool{}
+	workflows := []graphWorkflow{}
+
+	for _, ns := range allNamespaces(b.TenantConfig) {
+		if namespace != "" && ns.Name != namespace {
+			continue
+		}
+
+		for _, wf := range ns.Workflows {
+			if len(names) > 0 && !containsString(names, wf.Name) {
+				continue
+			}
+
+			found[wf.Name] = true
+			workflows = append(workflows, layoutWorkflow(fmt.Sprintf("w%d", len(workflows)), ns.Name, wf, modules))
+		}
+	}
+
+	missing := []string{}
+
+	for _, name := range names {
+		if !found[name] {
+			missing = append(missing, name)
+		}
+	}
+
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("workflows not found: %s", strings.Join(missing, ", "))
+	}
+
+	return workflows, nil
+}
+
+// layoutWorkflow adds a node for each trigger and step module of the workflow, with edges from each
+// node to every node of the step after it, so that groups fan out from and back into the steps around them.
+func layoutWorkflow(id, namespace string, wf tenant.Workflow, modules map[string]*ModuleDir) graphWorkflow {
+	g := graphWorkflow{id: id, namespace: namespace, name: wf.Name}
+
+	prev := []string{}
+
+	for i, trigger := range wf.Triggers {
+		node := graphNode{
+			id:      fmt.Sprintf("%s_t%d", id, i),
+			label:   []string{trigger.Topic, trigger.Source},
+			trigger: true,
+		}
+
+		g.nodes = append(g.nodes, node)
+		prev = append(prev, node.id)
+	}
+
+	for i, step := range wf.Steps {
+		mods := step.Group
+		if !step.IsGroup() {
+			mods = []executable.ExecutableMod{step.ExecutableMod}
+		}
+
+		current := []string{}
+
+		for j, mod := range mods {
+			node := moduleNode(fmt.Sprintf("%s_s%d_%d", id, i, j), mod.FQMN, modules[mod.FQMN])
+
+			g.nodes = append(g.nodes, node)
+			current = append(current, node.id)
+
+			for _, p := range prev {
+				g.edges = append(g.edges, [2]string{p, node.id})
+			}
+		}
+
+		prev = current
+	}
+
+	return g
+}
+
+// moduleNode returns a node labelled with the module's name, language and namespace, or a highlighted node if it isn't in the project.
+func moduleNode(id, modFQMN string, module *ModuleDir) graphNode {
+	if module != nil {
+		return graphNode{id: id, label: []string{module.Name, fmt.Sprintf("%s, %s", module.Module.Lang, module.Module.Namespace)}}
+	}
+
+	FQMN, err := fqmn.Parse(modFQMN)
+	if err != nil {
+		return graphNode{id: id, label: []string{modFQMN, "malformed FQMN"}, missing: true}
+	}
+
+	return graphNode{id: id, label: []string{FQMN.Name, fmt.Sprintf("not in project, %s", FQMN.Namespace)}, missing: true}
+}
+
+func renderDOT(workflows []graphWorkflow) string {
+	out := &strings.Builder{}
+
+	out.WriteString("digraph workflows {\n")
+	out.WriteString("  rankdir=LR;\n")
+	out.WriteString("  node [shape=box];\n")
+
+	for _, g := range workflows {
+		fmt.Fprintf(out, "\n  subgraph cluster_%s {\n", g.id)
+		fmt.Fprintf(out, "    label=%s;\n", dotQuote(g.namespace+"/"+g.name))
+
+		for _, n := range g.nodes {
+			attrs := []string{"label=" + dotQuote(strings.Join(n.label, "\n"))}
+			if n.trigger {
+				attrs = append(attrs, "shape=ellipse")
+			}
+
+			if n.missing {
+				attrs = append(attrs, "color=red", "fontcolor=red", "style=dashed")
+			}
+
+			fmt.Fprintf(out, "    %s [%s];\n", n.id, strings.Join(attrs, ", "))
+		}
+
+		for _, e := range g.edges {
+			fmt.Fprintf(out, "    %s -> %s;\n", e[0], e[1])
+		}
+
+		out.WriteString("  }\n")
+	}
+
+	out.WriteString("}\n")
+
+	return out.String()
+}
+
+// dotQuote returns s as a quoted DOT string.
+func dotQuote(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `"`, `\"`)
+	s = strings.ReplaceAll(s, "\n", `\n`)
+
+	return `"` + s + `"`
+}
+
+func renderMermaid(workflows []graphWorkflow) string {
+	out := &strings.Builder{}
+
+	out.WriteString("flowchart LR\n")
+
+	missing := []string{}
+
+	for _, g := range workflows {
+		fmt.Fprintf(out, "  subgraph %s [%s]\n", g.id, mermaidQuote(g.namespace+"/"+g.name))
+
+		for _, n := range g.nodes {
+			label := mermaidQuote(strings.Join(n.label, "\n"))
+			if n.trigger {
+				fmt.Fprintf(out, "    %s([%s])\n", n.id, label)
+			} else {
+				fmt.Fprintf(out, "    %s[%s]\n", n.id, label)
+			}
+
+			if n.missing {
+				missing = append(missing, n.id)
+			}
+		}
+
+		for _, e := range g.edges {
+			fmt.Fprintf(out, "    %s --> %s\n", e[0], e[1])
+		}
+
+		out.WriteString("  end\n")
+	}
+
+	if len(missing) > 0 {
+		out.WriteString("  classDef missing stroke:#d00,color:#d00,stroke-dasharray:5 5\n")
+		fmt.Fprintf(out, "  class %s missing\n", strings.Join(missing, ","))
+	}
+
+	return out.String()
+}
+
+// mermaidQuote returns s as a quoted Mermaid label.
+func mermaidQuote(s string) string {
+	s = strings.ReplaceAll(s, `"`, "#quot;")
+	s = strings.ReplaceAll(s, "\n", "<br/>")
+
+	return `"` + s + `"`
+}
@@ -0,0 +1,90 @@
+package project
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testGraphTenantJSON = `{
+	"identifier": "com.suborbital.app",
+	"specVersion": 1,
+	"tenantVersion": 1,
+	"defaultNamespace": {
+		"name": "default",
+		"workflows": [
+			{
+				"name": "profile",
+				"triggers": [{"source": "nats", "topic": "user-viewed"}],
+				"steps": [
+					{"executableMod": {"fqmn": "fqmn://com.suborbital.app/default/fetch"}},
+					{"group": [{"fqmn": "fqmn://com.suborbital.app/default/avatar"}, {"fqmn": "fqmn://com.suborbital.app/default/posts"}]},
+					{"executableMod": {"fqmn": "fqmn://com.suborbital.app/default/render"}}
+				]
+			},
+			{
+				"name": "other",
+				"steps": [{"executableMod": {"fqmn": "fqmn://com.suborbital.app/default/fetch"}}]
+			}
+		]
+	}
+}`
+
+func TestWriteWorkflowGraph(t *testing.T) {
+	dir := t.TempDir()
+
+	for _, name := range []string{"fetch", "avatar", "render"} {
+		writeTestModule(t, dir, name, name)
+	}
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "tenant.json"), []byte(testGraphTenantJSON), 0644))
+
+	ctx, err := ForDirectory(dir)
+	require.NoError(t, err)
+
+	t.Run("renders DOT", func(t *testing.T) {
+		buf := &bytes.Buffer{}
+		require.NoError(t, ctx.WriteWorkflowGraph(buf, GraphFormatDOT, "", []string{"profile"}))
+
+		assert.Equal(t, `digraph workflows {
+  rankdir=LR;
+  node [shape=box];
+
+  subgraph cluster_w0 {
+    label="default/profile";
+    w0_t0 [label="user-viewed\nnats", shape=ellipse];
+    w0_s0_0 [label="fetch\nwat, default"];
+    w0_s1_0 [label="avatar\nwat, default"];
+    w0_s1_1 [label="posts\nnot in project, default", color=red, fontcolor=red, style=dashed];
+    w0_s2_0 [label="render\nwat, default"];
+    w0_t0 -> w0_s0_0;
+    w0_s0_0 -> w0_s1_0;
+    w0_s0_0 -> w0_s1_1;
+    w0_s1_0 -> w0_s2_0;
+    w0_s1_1 -> w0_s2_0;
+  }
+}
+`, buf.String())
+	})
+
+	t.Run("renders Mermaid", func(t *testing.T) {
+		buf := &bytes.Buffer{}
+		require.NoError(t, ctx.WriteWorkflowGraph(buf, GraphFormatMermaid, "default", nil))
+
+		out := buf.String()
+		assert.Contains(t, out, "flowchart LR\n")
+		assert.Contains(t, out, `  subgraph w1 ["default/other"]`)
+		assert.Contains(t, out, `    w0_t0(["user-viewed<br/>nats"])`)
+		assert.Contains(t, out, "    w0_s1_1 --> w0_s2_0\n")
+		assert.Contains(t, out, "  class w0_s1_1 missing\n")
+	})
+
+	t.Run("reports unknown workflows and formats", func(t *testing.T) {
+		assert.EqualError(t, ctx.WriteWorkflowGraph(&bytes.Buffer{}, GraphFormatDOT, "", []string{"profile", "nope"}), "workflows not found: nope")
+		assert.EqualError(t, ctx.WriteWorkflowGraph(&bytes.Buffer{}, "svg", "", nil), "svg is not a valid graph format (must be one of dot, mermaid)")
+	})
+}
@@ -0,0 +1,180 @@
+package project
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+)
+
+// Language describes everything subo needs to know to create, build and clean modules written in a particular language.
+type Language struct {
+	Name    string   `yaml:"name"`
+	Aliases []string `yaml:"aliases,omitempty"`
+	// DockerImage is the builder image (without tag) used by the Docker toolchain.
+	DockerImage string `yaml:"dockerImage,omitempty"`
+	// DockerRunArgs are extra arguments for the container engine's run command, such as a --platform for images
+	// that are only built for one platform.
+	DockerRunArgs []string `yaml:"dockerRunArgs,omitempty"`
+	// NativeCommands are the templated build commands for each OS (as named by runtime.GOOS).
+	NativeCommands map[string][]string `yaml:"nativeCommands,omitempty"`
+	// Prereqs are the files needed before building on each OS, and the commands to create them if missing.
+	Prereqs map[string][]Prereq `yaml:"prereqs,omitempty"`
+	// CompilerFlags are added to a module's CompilerFlags when its files match.
+	CompilerFlags []CompilerFlagRule `yaml:"compilerFlags,omitempty"`
+	// CleanTargets are the directories created by the toolchain that `subo clean` removes.
+	CleanTargets []string `yaml:"cleanTargets,omitempty"`
+	// OutputDirs are directories created by building that aren't sources, in addition to those of every language.
+	OutputDirs []string `yaml:"outputDirs,omitempty"`
+	// VersionCommands are the templated commands that print the versions of the native toolchain's tools,
+	// which are part of the build cache key so that modules are rebuilt when the tools are upgraded.
+	VersionCommands []string `yaml:"versionCommands,omitempty"`
+}
+
+// Prereq is a pre-requisite file paired with the native command needed to acquire that file (if it's missing).
+// Prereqs which need network access to acquire should set Mirror and/or OfflineCommand for offline builds.
+type Prereq struct {
+	File    string `yaml:"file"`
+	Command string `yaml:"command"`
+	// Mirror is the path within the offline mirror directory that File can be copied from.
+	Mirror string `yaml:"mirror,omitempty"`
+	// OfflineCommand is run instead of Command for offline builds, for example to install from a local cache.
+	OfflineCommand string `yaml:"offlineCommand,omitempty"`
+}
+
+// CompilerFlagRule adds Flags to a module's compiler flags if File (relative to the module) contains Contains.
+type CompilerFlagRule struct {
+	File     string `yaml:"file"`
+	Contains string `yaml:"contains"`
+	Flags    string `yaml:"flags"`
+}
+
+// languageFile is the format of a project's Languages.yaml.
+type languageFile struct {
+	Languages []Language `yaml:"languages"`
+}
+
+var (
+	languagesLock sync.RWMutex
+	languages     = map[string]Language{}
+	langAliases   = map[string]string{}
+)
+
+func init() {
+	for _, l := range builtinLanguages {
+		if err := RegisterLanguage(l); err != nil {
+			panic(err)
+		}
+	}
+}
+
+// RegisterLanguage adds a language to the registry, replacing any existing language with the same name.
+func RegisterLanguage(lang Language) error {
+	if lang.Name == "" {
+		return errors.New("language is missing a name")
+	}
+
+	languagesLock.Lock()
+	defer languagesLock.Unlock()
+
+	for _, alias := range lang.Aliases {
+		if _, exists := languages[alias]; exists && alias != lang.Name {
+			return fmt.Errorf("alias %s for language %s conflicts with an existing language", alias, lang.Name)
+		}
+
+		if existing, exists := langAliases[alias]; exists && existing != lang.Name {
+			return fmt.Errorf("alias %s for language %s is already used by %s", alias, lang.Name, existing)
+		}
+	}
+
+	// The aliases of the language being replaced are removed, as the new one may not have them.
+	if existing, exists := languages[lang.Name]; exists {
+		for _, alias := range existing.Aliases {
+			delete(langAliases, alias)
+		}
+	}
+
+	languages[lang.Name] = lang
+
+	for _, alias := range lang.Aliases {
+		langAliases[alias] = lang.Name
+	}
+
+	return nil
+}
+
+// LanguageFor returns the registered language with the given name or alias.
+func LanguageFor(nameOrAlias string) (*Language, bool) {
+	languagesLock.RLock()
+	defer languagesLock.RUnlock()
+
+	if actual, exists := langAliases[nameOrAlias]; exists {
+		nameOrAlias = actual
+	}
+
+	lang, exists := languages[nameOrAlias]
+	if !exists {
+		return nil, false
+	}
+
+	return &lang, true
+}
+
+// LanguageNames returns the names of all registered languages in alphabetical order.
+func LanguageNames() []string {
+	languagesLock.RLock()
+	defer languagesLock.RUnlock()
+
+	names := []string{}
+	for name := range languages {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+// ResolveLangAlias returns the language name for an alias, or the input if it is not an alias.
+func ResolveLangAlias(lang string) string {
+	languagesLock.RLock()
+	defer languagesLock.RUnlock()
+
+	if actual, exists := langAliases[lang]; exists {
+		return actual
+	}
+
+	return lang
+}
+
+// loadLanguagesFile registers any custom languages defined in the project's Languages.yaml.
+func loadLanguagesFile(cwd string) error {
+	filePath := filepath.Join(cwd, "Languages.yaml")
+
+	fileBytes, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+
+		return errors.Wrap(err, "failed to ReadFile for Languages.yaml")
+	}
+
+	file := &languageFile{}
+	if err := yaml.Unmarshal(fileBytes, file); err != nil {
+		return errors.Wrap(err, "failed to Unmarshal Languages.yaml")
+	}
+
+	for _, l := range file.Languages {
+		if err := RegisterLanguage(l); err != nil {
+			return errors.Wrapf(err, "failed to RegisterLanguage %s", l.Name)
+		}
+	}
+
+	return nil
+}
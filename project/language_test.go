@@ -0,0 +1,81 @@
+package project
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLanguageRegistry(t *testing.T) {
+	lang, exists := LanguageFor("rs")
+	require.True(t, exists)
+	assert.Equal(t, "rust", lang.Name)
+
+	assert.True(t, IsValidLang("rust"))
+	assert.False(t, IsValidLang("rs"))
+	assert.Equal(t, "tinygo", ResolveLangAlias("go"))
+	assert.Equal(t, "cobol", ResolveLangAlias("cobol"))
+
+	assert.Error(t, RegisterLanguage(Language{}))
+	assert.Error(t, RegisterLanguage(Language{Name: "rust2", Aliases: []string{"rs"}}))
+	assert.Error(t, RegisterLanguage(Language{Name: "rust2", Aliases: []string{"swift"}}))
+
+	// Replacing a language removes the aliases it no longer has, so other languages can use them.
+	require.NoError(t, RegisterLanguage(Language{Name: "test-replaced", Aliases: []string{"test-old"}}))
+	require.NoError(t, RegisterLanguage(Language{Name: "test-replaced", Aliases: []string{"test-new"}}))
+
+	_, exists = LanguageFor("test-old")
+	assert.False(t, exists)
+
+	lang, exists = LanguageFor("test-new")
+	require.True(t, exists)
+	assert.Equal(t, "test-replaced", lang.Name)
+
+	assert.NoError(t, RegisterLanguage(Language{Name: "test-other", Aliases: []string{"test-old"}}))
+}
+
+func TestLoadLanguagesFile(t *testing.T) {
+	dir := t.TempDir()
+
+	languagesYaml := `languages:
+  - name: zig
+    aliases: [zg]
+    dockerImage: example/builder-zig
+    nativeCommands:
+      linux:
+        - zig build-lib src/main.zig -femit-bin={{ .Name }}.wasm
+    cleanTargets: [zig-cache]
+`
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "Languages.yaml"), []byte(languagesYaml), 0644))
+	require.NoError(t, loadLanguagesFile(dir))
+
+	lang, exists := LanguageFor("zg")
+	require.True(t, exists)
+	assert.Equal(t, "zig", lang.Name)
+	assert.Equal(t, "example/builder-zig", lang.DockerImage)
+	assert.Equal(t, []string{"zig build-lib src/main.zig -femit-bin={{ .Name }}.wasm"}, lang.NativeCommands["linux"])
+	assert.Equal(t, []string{"zig-cache"}, lang.CleanTargets)
+	assert.True(t, IsValidLang("zig"))
+
+	// A project without a Languages.yaml is not an error.
+	assert.NoError(t, loadLanguagesFile(t.TempDir()))
+}
+
+func TestForDirectory_LanguagesFile(t *testing.T) {
+	dir := t.TempDir()
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, ProjectFileName), []byte(""), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "Languages.yaml"), []byte("languages:\n  - name: test-project-lang\n"), 0644))
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "hello"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "hello", ".module.yaml"), []byte("name: hello\nlang: test-project-lang\n"), 0644))
+
+	// The project root's Languages.yaml is used when running within one of its modules.
+	ctx, err := ForDirectory(filepath.Join(dir, "hello"))
+	require.NoError(t, err)
+	require.Len(t, ctx.Modules, 1)
+	assert.Equal(t, "test-project-lang", ctx.Modules[0].Module.Lang)
+}
@@ -0,0 +1,210 @@
+package project
+
+// jsPrereqs are the prerequisites shared by the languages built with a JS toolchain.
+var jsPrereqs = []Prereq{
+	{
+		File:           "node_modules",
+		Command:        "{{ .BuildConfig.JsToolchain }} install",
+		OfflineCommand: "{{ .BuildConfig.JsToolchain }} install --offline",
+	},
+}
+
+// jsVersionCommands print the versions of the tools used by the languages built with a JS toolchain.
+var jsVersionCommands = []string{"node --version", "{{ .BuildConfig.JsToolchain }} --version"}
+
+// builtinLanguages are the languages supported by subo out of the box.
+var builtinLanguages = []Language{
+	{
+		Name:        "rust",
+		Aliases:     []string{"rs"},
+		DockerImage: "suborbital/builder-rs",
+		NativeCommands: map[string][]string{
+			"darwin": {
+				"cargo vendor && cargo build --target wasm32-wasi --lib --release",
+				"cp target/wasm32-wasi/release/{{ .UnderscoreName }}.wasm ./{{ .Name }}.wasm",
+			},
+			"linux": {
+				"cargo vendor && cargo build --target wasm32-wasi --lib --release",
+				"cp target/wasm32-wasi/release/{{ .UnderscoreName }}.wasm ./{{ .Name }}.wasm",
+			},
+		},
+		Prereqs: map[string][]Prereq{
+			"darwin": {},
+			"linux":  {},
+		},
+		CleanTargets: []string{"target"},
+		// cargo vendor copies the dependencies' sources into the module.
+		OutputDirs:      []string{"vendor"},
+		VersionCommands: []string{"rustc --version", "cargo --version"},
+	},
+	{
+		Name:        "swift",
+		DockerImage: "suborbital/builder-swift",
+		NativeCommands: map[string][]string{
+			"darwin": {
+				"xcrun --toolchain swiftwasm swift build --triple wasm32-unknown-wasi -Xlinker --allow-undefined -Xlinker --export=allocate -Xlinker --export=deallocate -Xlinker --export=run_e -Xlinker --export=init",
+				"cp .build/debug/{{ .Name }}.wasm .",
+			},
+			"linux": {
+				"swift build --triple wasm32-unknown-wasi -Xlinker --allow-undefined -Xlinker --export=allocate -Xlinker --export=deallocate -Xlinker --export=run_e -Xlinker --export=init",
+				"cp .build/debug/{{ .Name }}.wasm .",
+			},
+		},
+		Prereqs: map[string][]Prereq{
+			"darwin": {},
+			"linux":  {},
+		},
+		CleanTargets:    []string{".build"},
+		VersionCommands: []string{"swift --version"},
+	},
+	{
+		Name:        "assemblyscript",
+		Aliases:     []string{"as"},
+		DockerImage: "suborbital/builder-as",
+		NativeCommands: map[string][]string{
+			"darwin": {
+				"npm run asbuild",
+			},
+			"linux": {
+				"chmod -R 777 ./",
+				"chmod +x ./node_modules/assemblyscript/bin/asc",
+				"./node_modules/assemblyscript/bin/asc src/index.ts --target release --use abort=src/index/abort {{ .CompilerFlags }}",
+			},
+		},
+		Prereqs: map[string][]Prereq{
+			"darwin": jsPrereqs,
+			"linux":  jsPrereqs,
+		},
+		// AS-JSON needs its transform to be passed to the compiler.
+		CompilerFlags: []CompilerFlagRule{
+			{
+				File:     "package.json",
+				Contains: "json-as",
+				Flags:    "--transform ./node_modules/json-as/transform",
+			},
+		},
+		VersionCommands: jsVersionCommands,
+	},
+	{
+		Name:        "tinygo",
+		Aliases:     []string{"go"},
+		DockerImage: "suborbital/builder-tinygo",
+		NativeCommands: map[string][]string{
+			"darwin": {
+				"go get -d",
+				"go mod tidy",
+				"tinygo build -o {{ .Name }}.wasm -target wasi .",
+			},
+			"linux": {
+				"go get -d",
+				"go mod tidy",
+				"tinygo build -o {{ .Name }}.wasm -target wasi .",
+			},
+		},
+		Prereqs: map[string][]Prereq{
+			"darwin": {},
+			"linux":  {},
+		},
+		VersionCommands: []string{"tinygo version", "go version"},
+	},
+	{
+		Name:          "grain",
+		Aliases:       []string{"gr"},
+		DockerImage:   "suborbital/builder-gr",
+		DockerRunArgs: []string{"--platform", "linux/amd64"},
+		NativeCommands: map[string][]string{
+			"darwin": {
+				"grain compile index.gr -I _lib -o {{ .Name }}.wasm",
+			},
+			"linux": {
+				"grain compile index.gr -I _lib -o {{ .Name }}.wasm",
+			},
+		},
+		Prereqs: map[string][]Prereq{
+			"darwin": {
+				{
+					File:    "_lib",
+					Command: "mkdir _lib",
+				},
+				{
+					File:    "_lib/_lib.tar.gz",
+					Command: "curl -L https://github.com/suborbital/reactr/archive/v{{ .ModuleDir.Module.APIVersion }}.tar.gz -o _lib/_lib.tar.gz",
+					Mirror:  "reactr/v{{ .ModuleDir.Module.APIVersion }}.tar.gz",
+				},
+				{
+					File:    "_lib/suborbital",
+					Command: "tar --strip-components=3 -C _lib -xvzf _lib/_lib.tar.gz **/api/grain/suborbital/*",
+				},
+			},
+			"linux": {
+				{
+					File:    "_lib",
+					Command: "mkdir _lib",
+				},
+				{
+					File:    "_lib/_lib.tar.gz",
+					Command: "curl -L https://github.com/suborbital/reactr/archive/v{{ .ModuleDir.Module.APIVersion }}.tar.gz -o _lib/_lib.tar.gz",
+					Mirror:  "reactr/v{{ .ModuleDir.Module.APIVersion }}.tar.gz",
+				},
+				{
+					File:    "_lib/suborbital",
+					Command: "tar --wildcards --strip-components=3 -C _lib -xvzf _lib/_lib.tar.gz **/api/grain/suborbital/*",
+				},
+			},
+		},
+		VersionCommands: []string{"grain --version"},
+	},
+	{
+		Name:        "typescript",
+		Aliases:     []string{"ts"},
+		DockerImage: "suborbital/builder-js",
+		NativeCommands: map[string][]string{
+			"darwin": {
+				"npm run build",
+			},
+			"linux": {
+				"npm run build",
+			},
+		},
+		Prereqs: map[string][]Prereq{
+			"darwin": jsPrereqs,
+			"linux":  jsPrereqs,
+		},
+		VersionCommands: jsVersionCommands,
+	},
+	{
+		Name:        "javascript",
+		Aliases:     []string{"js"},
+		DockerImage: "suborbital/builder-js",
+		NativeCommands: map[string][]string{
+			"darwin": {
+				"npm run build",
+			},
+			"linux": {
+				"npm run build",
+			},
+		},
+		Prereqs: map[string][]Prereq{
+			"darwin": jsPrereqs,
+			"linux":  jsPrereqs,
+		},
+		VersionCommands: jsVersionCommands,
+	},
+	{
+		Name:        "wat",
+		DockerImage: "suborbital/builder-wat",
+		NativeCommands: map[string][]string{
+			"darwin": {
+				"wat2wasm lib.wat -o {{ .Name }}.wasm",
+			},
+			"linux": {
+				"wat2wasm lib.wat -o {{ .Name }}.wasm",
+			},
+		},
+		Prereqs: map[string][]Prereq{
+			"darwin": {},
+			"linux":  {},
+		},
+		VersionCommands: []string{"wat2wasm --version"},
+	},
+}
@@ -0,0 +1,236 @@
+package project
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+
+	"github.com/suborbital/systemspec/tenant"
+)
+
+// overlayExtensions are the file extensions that an environment's tenant config overlay can have, in order of preference.
+var overlayExtensions = []string{".yaml", ".yml", ".json"}
+
+// overlayForbiddenKeys are top-level tenant config keys that are managed by subo and can't be set by an overlay.
+var overlayForbiddenKeys = []string{"tenantVersion", "modules"}
+
+// TenantOverlayPath returns the path of the tenant config overlay for the environment (e.g. tenant.staging.yaml).
+func TenantOverlayPath(cwd, env string) (string, error) {
+	if env == "" || strings.ContainsAny(env, `/\`) || strings.HasPrefix(env, ".") {
+		return "", fmt.Errorf("%q is not a valid environment name", env)
+	}
+
+	for _, ext := range overlayExtensions {
+		filePath := filepath.Join(cwd, fmt.Sprintf("tenant.%s%s", env, ext))
+
+		if _, err := os.Stat(filePath); err == nil {
+			return filePath, nil
+		} else if !os.IsNotExist(err) {
+			return "", errors.Wrapf(err, "failed to Stat %s", filePath)
+		}
+	}
+
+	return "", fmt.Errorf("environment %s has no tenant config overlay (tenant.%s.yaml)", env, env)
+}
+
+// ApplyEnvironment merges the environment's tenant config overlay into the context's tenant config.
+// The packaged bundle uses the merged config, but only the base config is ever written back to tenant.json.
+func (b *Context) ApplyEnvironment(env string) error {
+	if b.TenantConfig == nil {
+		return fmt.Errorf("environment %s can't be applied without a tenant.json", env)
+	}
+
+	overlayPath, err := TenantOverlayPath(b.Cwd, env)
+	if err != nil {
+		return err
+	}
+
+	overlayBytes, err := ioutil.ReadFile(overlayPath)
+	if err != nil {
+		return errors.Wrapf(err, "failed to ReadFile for %s", overlayPath)
+	}
+
+	merged, err := MergeTenantConfig(b.TenantConfig, overlayBytes)
+	if err != nil {
+		return &FileError{File: filepath.Base(overlayPath), Err: errors.Wrapf(err, "failed to merge %s", filepath.Base(overlayPath))}
+	}
+
+	b.TenantConfig = merged
+	b.Env = env
+
+	return nil
+}
+
+// SaveTenantConfig writes the context's tenant config to tenant.json if there isn't one yet. Otherwise only
+// the tenant version is written back, so that Queries.yaml, Connections.yaml and the environment's overlay
+// never leak into it.
+func (b *Context) SaveTenantConfig() error {
+	base, err := readTenantConfig(b.Cwd)
+	if err != nil {
+		if os.IsNotExist(errors.Cause(err)) {
+			return WriteTenantConfig(b.Cwd, b.TenantConfig)
+		}
+
+		return errors.Wrap(err, "failed to readTenantConfig")
+	}
+
+	base.TenantVersion = b.TenantConfig.TenantVersion
+
+	return WriteTenantConfig(b.Cwd, base)
+}
+
+// MergeTenantConfig deep-merges an overlay (in YAML or JSON, with the same structure as tenant.json) into a copy of the base tenant config.
+// Maps are merged key by key, lists whose items all have a name (such as namespaces, workflows, queries
+// and connections) are merged item by item by name, other values are replaced and null removes a value.
+func MergeTenantConfig(base *tenant.Config, overlay []byte) (*tenant.Config, error) {
+	baseJSON, err := json.Marshal(base)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to Marshal base config")
+	}
+
+	baseMap := map[string]interface{}{}
+	if err := json.Unmarshal(baseJSON, &baseMap); err != nil {
+		return nil, errors.Wrap(err, "failed to Unmarshal base config")
+	}
+
+	var overlayVal interface{}
+	if err := yaml.Unmarshal(overlay, &overlayVal); err != nil {
+		return nil, errors.Wrap(err, "failed to Unmarshal overlay")
+	}
+
+	overlayMap, isMap := normalizeYAML(overlayVal).(map[string]interface{})
+	if !isMap {
+		if overlayVal == nil {
+			// An empty overlay changes nothing.
+			overlayMap = map[string]interface{}{}
+		} else {
+			return nil, errors.New("overlay must be a map of tenant config settings")
+		}
+	}
+
+	for _, key := range overlayForbiddenKeys {
+		if _, exists := overlayMap[key]; exists {
+			return nil, fmt.Errorf("%s is managed by subo and can't be set by an overlay", key)
+		}
+	}
+
+	mergedJSON, err := json.Marshal(mergeValues(baseMap, overlayMap))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to Marshal merged config")
+	}
+
+	// Unknown fields are rejected so that typos in an overlay don't silently do nothing.
+	decoder := json.NewDecoder(bytes.NewReader(mergedJSON))
+	decoder.DisallowUnknownFields()
+
+	merged := &tenant.Config{}
+	if err := decoder.Decode(merged); err != nil {
+		return nil, errors.Wrap(err, "invalid overlay")
+	}
+
+	return merged, nil
+}
+
+// mergeValues returns overlay merged into base.
+func mergeValues(base, overlay interface{}) interface{} {
+	switch o := overlay.(type) {
+	case map[string]interface{}:
+		b, isMap := base.(map[string]interface{})
+		if !isMap {
+			return o
+		}
+
+		merged := map[string]interface{}{}
+		for k, v := range b {
+			merged[k] = v
+		}
+
+		for k, v := range o {
+			if v == nil {
+				delete(merged, k)
+				continue
+			}
+
+			merged[k] = mergeValues(b[k], v)
+		}
+
+		return merged
+
+	case []interface{}:
+		b, isList := base.([]interface{})
+		if !isList || !allNamed(b) || !allNamed(o) {
+			return o
+		}
+
+		merged := append([]interface{}{}, b...)
+
+		for _, item := range o {
+			name := item.(map[string]interface{})["name"]
+
+			found := false
+
+			for i := range merged {
+				if merged[i].(map[string]interface{})["name"] == name {
+					merged[i] = mergeValues(merged[i], item)
+					found = true
+
+					break
+				}
+			}
+
+			if !found {
+				merged = append(merged, item)
+			}
+		}
+
+		return merged
+	}
+
+	return overlay
+}
+
+// allNamed returns true if every item in the list is a map with a string name.
+func allNamed(list []interface{}) bool {
+	for _, item := range list {
+		m, isMap := item.(map[string]interface{})
+		if !isMap {
+			return false
+		}
+
+		if _, isString := m["name"].(string); !isString {
+			return false
+		}
+	}
+
+	return true
+}
+
+// normalizeYAML converts the map[interface{}]interface{} values produced by yaml.v2 into map[string]interface{} so they can be merged with JSON.
+func normalizeYAML(val interface{}) interface{} {
+	switch v := val.(type) {
+	case map[interface{}]interface{}:
+		m := map[string]interface{}{}
+		for k, item := range v {
+			m[fmt.Sprint(k)] = normalizeYAML(item)
+		}
+
+		return m
+
+	case []interface{}:
+		list := make([]interface{}, len(v))
+		for i, item := range v {
+			list[i] = normalizeYAML(item)
+		}
+
+		return list
+	}
+
+	return val
+}
@@ -0,0 +1,119 @@
+package project
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/suborbital/systemspec/tenant"
+)
+
+const testTenantJSON = `{
+	"identifier": "com.suborbital.app",
+	"specVersion": 1,
+	"tenantVersion": 3,
+	"defaultNamespace": {
+		"name": "default",
+		"workflows": [{"name": "hello", "steps": [{"executableMod": {"fqmn": "fqmn://com.suborbital.app/default/hello@v1.0.0"}}], "response": "hello"}],
+		"connections": [
+			{"type": "redis", "name": "cache", "config": {"serverAddress": "redis://localhost:6379"}},
+			{"type": "nats", "name": "events", "config": {"serverAddress": "nats://localhost:4222"}}
+		]
+	}
+}`
+
+const testStagingOverlay = `defaultNamespace:
+  connections:
+    - name: cache
+      config:
+        serverAddress: redis://staging:6379
+        password: hunter2
+    - type: kafka
+      name: stream
+      config:
+        brokerAddress: kafka://staging:9092
+  capabilities:
+    logger:
+      enabled: true
+`
+
+func TestApplyEnvironment(t *testing.T) {
+	dir := t.TempDir()
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "tenant.json"), []byte(testTenantJSON), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "tenant.staging.yaml"), []byte(testStagingOverlay), 0644))
+
+	t.Run("merges the overlay into the base config", func(t *testing.T) {
+		ctx, err := ForDirectory(dir)
+		require.NoError(t, err)
+		require.NoError(t, ctx.ApplyEnvironment("staging"))
+
+		cfg := ctx.TenantConfig
+		assert.Equal(t, "com.suborbital.app", cfg.Identifier)
+		assert.Equal(t, int64(3), cfg.TenantVersion)
+		assert.Equal(t, "hello", cfg.DefaultNamespace.Workflows[0].Name)
+		require.NotNil(t, cfg.DefaultNamespace.Capabilities)
+		assert.True(t, cfg.DefaultNamespace.Capabilities.Logger.Enabled)
+
+		conns := cfg.DefaultNamespace.Connections
+		require.Len(t, conns, 3)
+		assert.Equal(t, "redis", conns[0].Type)
+		assert.Equal(t, map[string]string{"serverAddress": "redis://staging:6379", "password": "hunter2"}, conns[0].Config)
+		assert.Equal(t, "nats://localhost:4222", conns[1].Config["serverAddress"])
+		assert.Equal(t, "stream", conns[2].Name)
+	})
+
+	t.Run("only writes the tenant version back to tenant.json", func(t *testing.T) {
+		ctx, err := ForDirectory(dir)
+		require.NoError(t, err)
+		require.NoError(t, ctx.ApplyEnvironment("staging"))
+
+		ctx.TenantConfig.TenantVersion++
+		require.NoError(t, ctx.SaveTenantConfig())
+
+		base, err := readTenantConfig(dir)
+		require.NoError(t, err)
+		assert.Equal(t, int64(4), base.TenantVersion)
+		assert.Len(t, base.DefaultNamespace.Connections, 2)
+		assert.Nil(t, base.DefaultNamespace.Capabilities)
+	})
+
+	t.Run("reports missing overlays", func(t *testing.T) {
+		ctx, err := ForDirectory(dir)
+		require.NoError(t, err)
+		assert.EqualError(t, ctx.ApplyEnvironment("prod"), "environment prod has no tenant config overlay (tenant.prod.yaml)")
+		assert.Error(t, ctx.ApplyEnvironment("../staging"))
+	})
+}
+
+func TestMergeTenantConfig(t *testing.T) {
+	base := &tenant.Config{}
+	require.NoError(t, base.Unmarshal([]byte(testTenantJSON)))
+
+	t.Run("removes null values", func(t *testing.T) {
+		merged, err := MergeTenantConfig(base, []byte("defaultNamespace:\n  connections: null\n"))
+		require.NoError(t, err)
+		assert.Empty(t, merged.DefaultNamespace.Connections)
+		assert.Len(t, base.DefaultNamespace.Connections, 2, "the base config should not be modified")
+	})
+
+	t.Run("replaces lists of unnamed items", func(t *testing.T) {
+		merged, err := MergeTenantConfig(base, []byte("defaultNamespace:\n  workflows:\n    - name: hello\n      steps: [{executableMod: {fqmn: goodbye}}]\n"))
+		require.NoError(t, err)
+		require.Len(t, merged.DefaultNamespace.Workflows, 1)
+		require.Len(t, merged.DefaultNamespace.Workflows[0].Steps, 1)
+		assert.Equal(t, "goodbye", merged.DefaultNamespace.Workflows[0].Steps[0].FQMN)
+		assert.Equal(t, "hello", merged.DefaultNamespace.Workflows[0].Response)
+	})
+
+	t.Run("rejects unknown and managed settings", func(t *testing.T) {
+		_, err := MergeTenantConfig(base, []byte("defaultNamespace:\n  conections: []\n"))
+		assert.Error(t, err)
+
+		_, err = MergeTenantConfig(base, []byte("tenantVersion: 12\n"))
+		assert.EqualError(t, err, "tenantVersion is managed by subo and can't be set by an overlay")
+	})
+}
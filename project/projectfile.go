@@ -0,0 +1,222 @@
+package project
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+)
+
+// ProjectFileName is the name of the optional project configuration file at the project root.
+const ProjectFileName = "subo.yaml"
+
+// settingsEnvPrefix is the prefix of environment variables that set defaults, e.g. SUBO_BUILD_BUILDER_TAG.
+const settingsEnvPrefix = "SUBO"
+
+// userConfigFileName is the name of the user's config file within their subo config directory.
+const userConfigFileName = "config.yaml"
+
+// ProjectFile is the format of a project's subo.yaml (and of the user's config file, which can hold the same defaults).
+type ProjectFile struct {
+	Modules DiscoveryConfig `yaml:"modules,omitempty"`
+	// Build, Package, Push and Deploy are defaults for the settings of the corresponding commands.
+	Build   Settings `yaml:"build,omitempty"`
+	Package Settings `yaml:"package,omitempty"`
+	Push    Settings `yaml:"push,omitempty"`
+	Deploy  Settings `yaml:"deploy,omitempty"`
+	// Secrets configures how secret:// references in connection configs are looked up.
+	Secrets SecretsConfig `yaml:"secrets,omitempty"`
+}
+
+// Settings are default values for a command's settings, keyed by the name of the setting's flag
+// (either as written on the command line, e.g. builder-tag, or in camel case, e.g. builderTag).
+type Settings map[string]interface{}
+
+// Section returns the settings for the named section (build, package, push or deploy).
+func (p *ProjectFile) Section(name string) Settings {
+	switch name {
+	case "build":
+		return p.Build
+	case "package":
+		return p.Package
+	case "push":
+		return p.Push
+	case "deploy":
+		return p.Deploy
+	}
+
+	return nil
+}
+
+// Defaults are the default values for commands' settings, taken from (in order of precedence) the environment,
+// the project's subo.yaml and the user's config file.
+type Defaults struct {
+	project *ProjectFile
+	user    *ProjectFile
+}
+
+// LoadDefaults loads the defaults for the project containing dir.
+func LoadDefaults(dir string) (*Defaults, error) {
+	root, err := FindProjectRoot(dir)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to FindProjectRoot")
+	}
+
+	projectFile, err := ReadProjectFile(root)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to ReadProjectFile")
+	}
+
+	userConfig, err := ReadUserConfig()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to ReadUserConfig")
+	}
+
+	return &Defaults{project: projectFile, user: userConfig}, nil
+}
+
+// Value returns the default for a setting in the named section, where name is the setting's flag (e.g. builder-tag).
+// Lists are returned comma-separated, as flags accept them.
+func (d *Defaults) Value(section, name string) (string, bool) {
+	if val, exists := os.LookupEnv(settingEnvKey(section, name)); exists {
+		return val, true
+	}
+
+	for _, file := range []*ProjectFile{d.project, d.user} {
+		for key, val := range file.Section(section) {
+			if flagName(key) == name {
+				return settingString(val), true
+			}
+		}
+	}
+
+	return "", false
+}
+
+// Names returns the flag names of the settings in the named section of the project file and the user's config file.
+func (d *Defaults) Names(section string) []string {
+	names := []string{}
+
+	for _, file := range []*ProjectFile{d.project, d.user} {
+		for key := range file.Section(section) {
+			names = append(names, flagName(key))
+		}
+	}
+
+	return names
+}
+
+// settingEnvKey returns the environment variable for a setting, e.g. SUBO_BUILD_BUILDER_TAG.
+func settingEnvKey(section, name string) string {
+	return strings.ToUpper(strings.Join([]string{settingsEnvPrefix, section, strings.ReplaceAll(name, "-", "_")}, "_"))
+}
+
+// flagName converts a camel case setting name (builderTag) into a flag name (builder-tag).
+func flagName(key string) string {
+	name := strings.Builder{}
+
+	for i, r := range key {
+		if unicode.IsUpper(r) {
+			if i > 0 {
+				name.WriteRune('-')
+			}
+
+			r = unicode.ToLower(r)
+		}
+
+		name.WriteRune(r)
+	}
+
+	return name.String()
+}
+
+// settingString converts a value from a YAML file into the string form accepted by flags.
+func settingString(val interface{}) string {
+	if list, isList := val.([]interface{}); isList {
+		items := make([]string, len(list))
+		for i, item := range list {
+			items[i] = fmt.Sprint(item)
+		}
+
+		return strings.Join(items, ",")
+	}
+
+	return fmt.Sprint(val)
+}
+
+// DiscoveryConfig controls how modules are found within a project.
+type DiscoveryConfig struct {
+	// Paths is an explicit list of module directories relative to the project root. If set, the
+	// project is not searched for modules.
+	Paths []string `yaml:"paths,omitempty"`
+	// MaxDepth is how many directories deep the project is searched for modules.
+	MaxDepth int `yaml:"maxDepth,omitempty"`
+	// Ignore are glob patterns for directories that are not searched, matched against both the
+	// directory's name and its path relative to the project root. They are added to the defaults.
+	Ignore []string `yaml:"ignore,omitempty"`
+}
+
+// ReadProjectFile reads the subo.yaml in the project directory, returning an empty ProjectFile if there isn't one.
+func ReadProjectFile(dir string) (*ProjectFile, error) {
+	fullDir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get Abs path")
+	}
+
+	return readProjectFileAt(filepath.Join(fullDir, ProjectFileName))
+}
+
+// FindProjectRoot returns the root of the project containing dir, which is the nearest of dir and its parents to
+// have a subo.yaml. If none of them do, dir is the root.
+func FindProjectRoot(dir string) (string, error) {
+	fullDir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to get Abs path")
+	}
+
+	for current := fullDir; ; current = filepath.Dir(current) {
+		if _, err := os.Stat(filepath.Join(current, ProjectFileName)); err == nil {
+			return current, nil
+		}
+
+		if filepath.Dir(current) == current {
+			return fullDir, nil
+		}
+	}
+}
+
+// ReadUserConfig reads the user's config file (config.yaml in the subo directory of the user's
+// config directory), returning an empty ProjectFile if there isn't one.
+func ReadUserConfig() (*ProjectFile, error) {
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		// Without a home directory there can't be a user config.
+		return &ProjectFile{}, nil
+	}
+
+	return readProjectFileAt(filepath.Join(configDir, "subo", userConfigFileName))
+}
+
+func readProjectFileAt(filePath string) (*ProjectFile, error) {
+	project := &ProjectFile{}
+
+	fileBytes, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return project, nil
+		}
+
+		return nil, errors.Wrapf(err, "failed to ReadFile for %s", filePath)
+	}
+
+	if err := yaml.UnmarshalStrict(fileBytes, project); err != nil {
+		return nil, errors.Wrapf(err, "failed to Unmarshal %s", filePath)
+	}
+
+	return project, nil
+}
@@ -0,0 +1,138 @@
+package project
+
+import (
+	"encoding/json"
+	"io"
+	"path/filepath"
+	"sort"
+
+	"github.com/pkg/errors"
+
+	"github.com/suborbital/subo/subo/release"
+)
+
+const (
+	sarifVersion = "2.1.0"
+	sarifSchema  = "https://json.schemastore.org/sarif-2.1.0.json"
+)
+
+type sarifLog struct {
+	Version string     `json:"version"`
+	Schema  string     `json:"$schema"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	Version        string      `json:"version"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID               string       `json:"id"`
+	ShortDescription sarifMessage `json:"shortDescription"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID    string          `json:"ruleId"`
+	Level     string          `json:"level"`
+	Message   sarifMessage    `json:"message"`
+	Locations []sarifLocation `json:"locations,omitempty"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           *sarifRegion          `json:"region,omitempty"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+type sarifRegion struct {
+	StartLine int `json:"startLine"`
+}
+
+// WriteSARIF writes problems as a SARIF log, the format used by code scanning tools to show problems alongside the source.
+func WriteSARIF(w io.Writer, problems []Problem) error {
+	ruleIDs := []string{}
+	for id := range RuleDescriptions {
+		ruleIDs = append(ruleIDs, id)
+	}
+
+	sort.Strings(ruleIDs)
+
+	rules := make([]sarifRule, len(ruleIDs))
+	for i, id := range ruleIDs {
+		rules[i] = sarifRule{ID: id, ShortDescription: sarifMessage{Text: RuleDescriptions[id]}}
+	}
+
+	results := make([]sarifResult, len(problems))
+
+	for i, p := range problems {
+		result := sarifResult{
+			RuleID:  p.Rule,
+			Level:   "error",
+			Message: sarifMessage{Text: p.Message},
+		}
+
+		if p.File != "" {
+			location := sarifLocation{
+				PhysicalLocation: sarifPhysicalLocation{
+					ArtifactLocation: sarifArtifactLocation{URI: filepath.ToSlash(p.File)},
+				},
+			}
+
+			if p.Line > 0 {
+				location.PhysicalLocation.Region = &sarifRegion{StartLine: p.Line}
+			}
+
+			result.Locations = []sarifLocation{location}
+		}
+
+		results[i] = result
+	}
+
+	log := sarifLog{
+		Version: sarifVersion,
+		Schema:  sarifSchema,
+		Runs: []sarifRun{{
+			Tool: sarifTool{
+				Driver: sarifDriver{
+					Name:           "subo",
+					Version:        release.SuboVersion,
+					InformationURI: "https://github.com/suborbital/subo",
+					Rules:          rules,
+				},
+			},
+			Results: results,
+		}},
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+
+	if err := encoder.Encode(log); err != nil {
+		return errors.Wrap(err, "failed to Encode")
+	}
+
+	return nil
+}
@@ -0,0 +1,404 @@
+package project
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+
+	"github.com/suborbital/subo/subo/util"
+	"github.com/suborbital/systemspec/tenant"
+)
+
+// secretRefPrefix marks a connection config value that refers to a secret by name, e.g. secret://db-password.
+const secretRefPrefix = "secret://"
+
+// secretEnvPrefix is the prefix of the environment variables that secrets can be set with, e.g. SUBO_SECRET_DB_PASSWORD.
+const secretEnvPrefix = "SUBO_SECRET_"
+
+var (
+	// envRefRegex matches ${VAR} and ${VAR:-default}, as well as $${...}, which escapes a literal ${...}.
+	envRefRegex     = regexp.MustCompile(`\$?\$\{([A-Za-z_][A-Za-z0-9_]*)(:-[^}]*)?\}`)
+	secretNameRegex = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.\-/]*$`)
+)
+
+// SecretProvider looks up the secrets referred to as secret://<name> in connection configs.
+type SecretProvider interface {
+	// Secret returns the value of the named secret, or false if the provider doesn't have it.
+	Secret(name string) (string, bool, error)
+}
+
+var (
+	secretProvidersLock sync.RWMutex
+	secretProviders     []SecretProvider
+)
+
+// RegisterSecretProvider adds a provider that secrets are looked up in after the environment and Secrets.yaml,
+// and before the command set in subo.yaml. Providers are consulted in the order they were registered.
+func RegisterSecretProvider(p SecretProvider) {
+	secretProvidersLock.Lock()
+	defer secretProvidersLock.Unlock()
+
+	secretProviders = append(secretProviders, p)
+}
+
+// SecretsConfig is the secrets section of subo.yaml.
+type SecretsConfig struct {
+	// Command is run (with the secret's name in $SUBO_SECRET_NAME) to look up secrets that aren't found elsewhere,
+	// e.g. `vault kv get -field=value secret/$SUBO_SECRET_NAME`. Its output is the secret's value.
+	Command string `yaml:"command,omitempty"`
+}
+
+// SecretError is a connection config value containing a reference that couldn't be resolved.
+type SecretError struct {
+	Namespace  string
+	Connection string
+	Key        string
+	// Ref is the reference that couldn't be resolved, e.g. secret://db-password or ${DB_PASSWORD}.
+	Ref string
+	Err error
+}
+
+func (e *SecretError) Error() string {
+	return fmt.Sprintf("connection %s config %s: %s", e.Connection, e.Key, e.Err.Error())
+}
+
+// SecretEnvKey returns the environment variable that a secret can be set with, e.g. SUBO_SECRET_DB_PASSWORD.
+func SecretEnvKey(name string) string {
+	key := strings.Builder{}
+	key.WriteString(secretEnvPrefix)
+
+	for _, r := range strings.ToUpper(name) {
+		if (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			key.WriteRune(r)
+		} else {
+			key.WriteRune('_')
+		}
+	}
+
+	return key.String()
+}
+
+// envSecretProvider looks up secrets in the environment variables named after them.
+type envSecretProvider struct{}
+
+func (envSecretProvider) Secret(name string) (string, bool, error) {
+	val, exists := os.LookupEnv(SecretEnvKey(name))
+
+	return val, exists, nil
+}
+
+// commandSecretProvider looks up secrets by running the command from subo.yaml.
+type commandSecretProvider struct {
+	command string
+	dir     string
+}
+
+func (c *commandSecretProvider) Secret(name string) (string, bool, error) {
+	cmd := util.ShellCmd(c.command)
+	cmd.Dir = c.dir
+	cmd.Env = map[string]string{"SUBO_SECRET_NAME": name}
+
+	result, err := util.NewCommandLineExecutor(util.SilentOutput, nil).Exec(context.Background(), cmd)
+	if err != nil {
+		if result != nil && strings.TrimSpace(result.Stderr) != "" {
+			return "", false, errors.Wrapf(err, "secrets command failed: %s", strings.TrimSpace(result.Stderr))
+		}
+
+		return "", false, errors.Wrap(err, "secrets command failed")
+	}
+
+	return strings.TrimRight(result.Stdout, "\r\n"), true, nil
+}
+
+// ResolvedTenantConfig returns a copy of the context's tenant config with the ${ENV_VAR} and secret:// references
+// in its connections resolved. It is only for configs that are sent out (such as in bundles), as secrets are only
+// looked up when it's called and the copy can't be written to tenant.json.
+func (b *Context) ResolvedTenantConfig() (*tenant.Config, error) {
+	if b.TenantConfig == nil {
+		return nil, errors.New("no tenant.json found")
+	}
+
+	secrets, err := b.resolver()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to resolver")
+	}
+
+	resolved, secretErrs, err := secrets.resolveConfig(b.TenantConfig)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to resolveConfig")
+	}
+
+	if len(secretErrs) > 0 {
+		msgs := make([]string, len(secretErrs))
+		for i, e := range secretErrs {
+			msgs[i] = e.Error()
+		}
+
+		return nil, fmt.Errorf("failed to resolve secrets: %s", strings.Join(msgs, "; "))
+	}
+
+	return resolved, nil
+}
+
+// resolver returns the context's secret resolver, creating it when it's first needed.
+func (b *Context) resolver() (*secretResolver, error) {
+	if b.secrets == nil {
+		secrets, err := newSecretResolver(b.Cwd, b.Project)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to newSecretResolver")
+		}
+
+		b.secrets = secrets
+	}
+
+	return b.secrets, nil
+}
+
+// hasResolvedReferences returns true if any of the tenant config's connection config values has been resolved, i.e.
+// it replaces a reference in the project's unresolved tenant config and isn't in any of its configs as it is.
+func hasResolvedReferences(cwd string, cfg *tenant.Config) bool {
+	unresolved := []map[string]map[string]string{}
+	for _, unresolvedCfg := range unresolvedTenantConfigs(cwd) {
+		unresolved = append(unresolved, connectionsByKey(unresolvedCfg))
+	}
+
+	for conn, config := range connectionsByKey(cfg) {
+		for key, val := range config {
+			replacesRef, isUnresolved := false, false
+
+			for _, connections := range unresolved {
+				unresolvedVal, exists := connections[conn][key]
+				if !exists {
+					continue
+				}
+
+				if unresolvedVal == val {
+					isUnresolved = true
+				} else if hasReference(unresolvedVal) {
+					replacesRef = true
+				}
+			}
+
+			if replacesRef && !isUnresolved {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// unresolvedTenantConfigs returns the project's tenant config as it is on disk (tenant.json with Connections.yaml
+// merged in), both on its own and with each environment's overlay. Files that can't be read are skipped.
+func unresolvedTenantConfigs(cwd string) []*tenant.Config {
+	base, err := readTenantConfig(cwd)
+	if err != nil {
+		base = &tenant.Config{}
+	}
+
+	applyNamespaceFiles(cwd, base)
+
+	configs := []*tenant.Config{base}
+
+	files, err := ioutil.ReadDir(cwd)
+	if err != nil {
+		return configs
+	}
+
+	for _, file := range files {
+		if file.IsDir() || file.Name() == "tenant.json" || !strings.HasPrefix(file.Name(), "tenant.") {
+			continue
+		}
+
+		for _, ext := range overlayExtensions {
+			if !strings.HasSuffix(file.Name(), ext) {
+				continue
+			}
+
+			overlayBytes, err := ioutil.ReadFile(filepath.Join(cwd, file.Name()))
+			if err != nil {
+				break
+			}
+
+			if merged, err := MergeTenantConfig(base, overlayBytes); err == nil {
+				configs = append(configs, merged)
+			}
+
+			break
+		}
+	}
+
+	return configs
+}
+
+// connectionsByKey returns the config of each of the tenant config's connections, keyed by namespace/connection.
+func connectionsByKey(cfg *tenant.Config) map[string]map[string]string {
+	configs := map[string]map[string]string{}
+
+	namespaces := append([]tenant.NamespaceConfig{cfg.DefaultNamespace}, cfg.Namespaces...)
+	for _, ns := range namespaces {
+		for _, conn := range ns.Connections {
+			configs[ns.Name+"/"+conn.Name] = conn.Config
+		}
+	}
+
+	return configs
+}
+
+// hasReference returns true if a connection config value is a secret:// reference or contains an ${ENV_VAR}
+// reference, ignoring escaped $${...} ones.
+func hasReference(val string) bool {
+	if strings.HasPrefix(val, secretRefPrefix) {
+		return true
+	}
+
+	for _, ref := range envRefRegex.FindAllString(val, -1) {
+		if !strings.HasPrefix(ref, "$$") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// secretResolver resolves the ${ENV_VAR} and secret:// references in connection configs.
+type secretResolver struct {
+	providers []SecretProvider
+}
+
+// newSecretResolver returns a resolver that looks up secrets in the environment, the project's Secrets.yaml, any
+// registered providers and then the project's secrets command, in that order.
+func newSecretResolver(cwd string, projectFile *ProjectFile) (*secretResolver, error) {
+	secretsFile, err := OpenSecretsFile(cwd)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to OpenSecretsFile")
+	}
+
+	providers := []SecretProvider{envSecretProvider{}, secretsFile}
+
+	secretProvidersLock.RLock()
+	providers = append(providers, secretProviders...)
+	secretProvidersLock.RUnlock()
+
+	if projectFile != nil && projectFile.Secrets.Command != "" {
+		providers = append(providers, &commandSecretProvider{command: projectFile.Secrets.Command, dir: cwd})
+	}
+
+	return &secretResolver{providers: providers}, nil
+}
+
+// resolveConfig returns a copy of the tenant config with the references in its connection configs resolved, apart
+// from those that couldn't be, which are left as they are and returned as errors.
+func (r *secretResolver) resolveConfig(cfg *tenant.Config) (*tenant.Config, []*SecretError, error) {
+	resolved, err := copyTenantConfig(cfg)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failed to copyTenantConfig")
+	}
+
+	return resolved, r.resolve(resolved), nil
+}
+
+// resolve resolves the references in every connection's config in place, returning an error for each value that couldn't be resolved.
+func (r *secretResolver) resolve(cfg *tenant.Config) []*SecretError {
+	errs := []*SecretError{}
+
+	namespaces := []*tenant.NamespaceConfig{&cfg.DefaultNamespace}
+	for i := range cfg.Namespaces {
+		namespaces = append(namespaces, &cfg.Namespaces[i])
+	}
+
+	for _, ns := range namespaces {
+		for _, conn := range ns.Connections {
+			keys := make([]string, 0, len(conn.Config))
+			for key := range conn.Config {
+				keys = append(keys, key)
+			}
+
+			sort.Strings(keys)
+
+			for _, key := range keys {
+				val, ref, err := r.resolveValue(conn.Config[key])
+				if err != nil {
+					errs = append(errs, &SecretError{Namespace: ns.Name, Connection: conn.Name, Key: key, Ref: ref, Err: err})
+					continue
+				}
+
+				conn.Config[key] = val
+			}
+		}
+	}
+
+	return errs
+}
+
+// resolveValue resolves a value that is either a secret:// reference or a string containing ${ENV_VAR}
+// references. If it can't be, the reference that failed is returned along with the error.
+func (r *secretResolver) resolveValue(val string) (string, string, error) {
+	if strings.HasPrefix(val, secretRefPrefix) {
+		secret, err := r.secret(strings.TrimPrefix(val, secretRefPrefix))
+		if err != nil {
+			return "", val, err
+		}
+
+		return secret, "", nil
+	}
+
+	var failedRef string
+
+	var failedErr error
+
+	resolved := envRefRegex.ReplaceAllStringFunc(val, func(ref string) string {
+		if strings.HasPrefix(ref, "$$") {
+			return ref[1:]
+		}
+
+		match := envRefRegex.FindStringSubmatch(ref)
+
+		if envVal, exists := os.LookupEnv(match[1]); exists {
+			return envVal
+		}
+
+		if match[2] != "" {
+			return strings.TrimPrefix(match[2], ":-")
+		}
+
+		if failedErr == nil {
+			failedRef = ref
+			failedErr = fmt.Errorf("environment variable %s is not set", match[1])
+		}
+
+		return ref
+	})
+
+	if failedErr != nil {
+		return "", failedRef, failedErr
+	}
+
+	return resolved, "", nil
+}
+
+// secret looks up the named secret in each of the resolver's providers.
+func (r *secretResolver) secret(name string) (string, error) {
+	if !secretNameRegex.MatchString(name) {
+		return "", fmt.Errorf("%q is not a valid secret name", name)
+	}
+
+	for _, p := range r.providers {
+		val, exists, err := p.Secret(name)
+		if err != nil {
+			return "", errors.Wrapf(err, "failed to look up secret %s", name)
+		} else if exists {
+			return val, nil
+		}
+	}
+
+	return "", fmt.Errorf("secret %s was not found (add it with `subo secret set %s` or set %s)", name, name, SecretEnvKey(name))
+}
@@ -0,0 +1,211 @@
+package project
+
+import (
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testSecretsTenantJSON = `{
+	"identifier": "com.suborbital.app",
+	"specVersion": 1,
+	"tenantVersion": 1,
+	"defaultNamespace": {"name": "default"}
+}`
+
+const testSecretsConnections = `defaultNamespace:
+  connections:
+    - type: redis
+      name: cache
+      config:
+        serverAddress: redis://${TEST_REDIS_HOST:-localhost}:6379
+        username: $${literal}
+        password: secret://redis-password
+`
+
+type testSecretProvider map[string]string
+
+func (p testSecretProvider) Secret(name string) (string, bool, error) {
+	val, exists := p[name]
+
+	return val, exists, nil
+}
+
+func setTestSecretsKey(t *testing.T) {
+	t.Setenv(SecretsKeyEnv, base64.StdEncoding.EncodeToString([]byte(strings.Repeat("k", secretsKeySize))))
+}
+
+func TestSecretResolver_ResolveValue(t *testing.T) {
+	t.Setenv("TEST_DB_HOST", "db.internal")
+	t.Setenv(SecretEnvKey("db-password"), "hunter2")
+
+	r := &secretResolver{providers: []SecretProvider{envSecretProvider{}}}
+
+	tests := []struct {
+		name    string
+		val     string
+		want    string
+		wantRef string
+	}{
+		{name: "plain values are unchanged", val: "redis://localhost", want: "redis://localhost"},
+		{name: "env vars", val: "postgresql://${TEST_DB_HOST}:5432", want: "postgresql://db.internal:5432"},
+		{name: "set env vars ignore defaults", val: "${TEST_DB_HOST:-localhost}", want: "db.internal"},
+		{name: "defaults", val: "${TEST_DB_UNSET:-localhost}", want: "localhost"},
+		{name: "escapes", val: "$${TEST_DB_HOST}", want: "${TEST_DB_HOST}"},
+		{name: "secrets", val: "secret://db-password", want: "hunter2"},
+		{name: "missing env vars", val: "${TEST_DB_UNSET}", wantRef: "${TEST_DB_UNSET}"},
+		{name: "missing secrets", val: "secret://nope", wantRef: "secret://nope"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ref, err := r.resolveValue(tt.val)
+			if tt.wantRef != "" {
+				assert.Error(t, err)
+				assert.Equal(t, tt.wantRef, ref)
+
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestSecretsFile(t *testing.T) {
+	dir := t.TempDir()
+	setTestSecretsKey(t)
+
+	secrets, err := OpenSecretsFile(dir)
+	require.NoError(t, err)
+	require.NoError(t, secrets.Set("redis-password", "hunter2"))
+	require.NoError(t, secrets.Write())
+
+	fileBytes, err := os.ReadFile(filepath.Join(dir, SecretsFileName))
+	require.NoError(t, err)
+	assert.NotContains(t, string(fileBytes), "hunter2")
+
+	secrets, err = OpenSecretsFile(dir)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"redis-password"}, secrets.Names())
+
+	val, exists, err := secrets.Secret("redis-password")
+	require.NoError(t, err)
+	assert.True(t, exists)
+	assert.Equal(t, "hunter2", val)
+
+	t.Run("fails with the wrong key", func(t *testing.T) {
+		t.Setenv(SecretsKeyEnv, base64.StdEncoding.EncodeToString([]byte(strings.Repeat("x", secretsKeySize))))
+
+		_, _, err := secrets.Secret("redis-password")
+		assert.EqualError(t, err, "failed to decrypt secret redis-password (is the secrets key correct?)")
+	})
+}
+
+func TestResolvedTenantConfig(t *testing.T) {
+	dir := t.TempDir()
+	setTestSecretsKey(t)
+
+	writeTestModule(t, dir, "hello", "hello")
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "tenant.json"), []byte(testSecretsTenantJSON), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "Connections.yaml"), []byte(testSecretsConnections), 0644))
+
+	t.Run("loads projects without resolving references", func(t *testing.T) {
+		ctx, err := ForDirectory(dir)
+		require.NoError(t, err)
+		assert.Equal(t, "secret://redis-password", ctx.TenantConfig.DefaultNamespace.Connections[0].Config["password"])
+
+		_, err = ctx.ResolvedTenantConfig()
+		assert.ErrorContains(t, err, "connection cache config password: secret redis-password was not found")
+
+		problems, err := Validate(dir, "")
+		require.NoError(t, err)
+		require.Len(t, problems, 1)
+		assert.Equal(t, RuleConnection, problems[0].Rule)
+		assert.Equal(t, "Connections.yaml", problems[0].File)
+		assert.Equal(t, 8, problems[0].Line)
+	})
+
+	t.Run("resolves references from Secrets.yaml", func(t *testing.T) {
+		secrets, err := OpenSecretsFile(dir)
+		require.NoError(t, err)
+		require.NoError(t, secrets.Set("redis-password", "hunter2"))
+		require.NoError(t, secrets.Write())
+
+		ctx, err := ForDirectory(dir)
+		require.NoError(t, err)
+
+		resolved, err := ctx.ResolvedTenantConfig()
+		require.NoError(t, err)
+
+		config := resolved.DefaultNamespace.Connections[0].Config
+		assert.Equal(t, "redis://localhost:6379", config["serverAddress"])
+		assert.Equal(t, "${literal}", config["username"])
+		assert.Equal(t, "hunter2", config["password"])
+
+		assert.Equal(t, "secret://redis-password", ctx.TenantConfig.DefaultNamespace.Connections[0].Config["password"])
+
+		ctx.TenantConfig.TenantVersion++
+		require.NoError(t, ctx.SaveTenantConfig())
+
+		tenantJSON, err := os.ReadFile(filepath.Join(dir, "tenant.json"))
+		require.NoError(t, err)
+		assert.NotContains(t, string(tenantJSON), "hunter2")
+		assert.Contains(t, string(tenantJSON), `"tenantVersion":2`)
+
+		assert.EqualError(t, WriteTenantConfig(dir, resolved), "refusing to write a tenant config with resolved secrets to tenant.json")
+	})
+
+	t.Run("prefers the environment to Secrets.yaml", func(t *testing.T) {
+		t.Setenv(SecretEnvKey("redis-password"), "from-env")
+
+		ctx, err := ForDirectory(dir)
+		require.NoError(t, err)
+
+		resolved, err := ctx.ResolvedTenantConfig()
+		require.NoError(t, err)
+		assert.Equal(t, "from-env", resolved.DefaultNamespace.Connections[0].Config["password"])
+	})
+
+	t.Run("looks up secrets in registered providers", func(t *testing.T) {
+		require.NoError(t, os.Remove(filepath.Join(dir, SecretsFileName)))
+
+		RegisterSecretProvider(testSecretProvider{"redis-password": "from-provider"})
+		t.Cleanup(func() {
+			secretProvidersLock.Lock()
+			secretProviders = nil
+			secretProvidersLock.Unlock()
+		})
+
+		ctx, err := ForDirectory(dir)
+		require.NoError(t, err)
+
+		resolved, err := ctx.ResolvedTenantConfig()
+		require.NoError(t, err)
+		assert.Equal(t, "from-provider", resolved.DefaultNamespace.Connections[0].Config["password"])
+	})
+
+	t.Run("refuses configs resolved from an environment's overlay", func(t *testing.T) {
+		overlay := "defaultNamespace:\n  connections:\n    - name: cache\n      config:\n        password: ${TEST_STAGING_PASSWORD}\n"
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "tenant.staging.yaml"), []byte(overlay), 0644))
+		t.Setenv("TEST_STAGING_PASSWORD", "staging-password")
+
+		ctx, err := ForDirectory(dir)
+		require.NoError(t, err)
+		require.NoError(t, ctx.ApplyEnvironment("staging"))
+
+		resolved, err := ctx.ResolvedTenantConfig()
+		require.NoError(t, err)
+		assert.Equal(t, "staging-password", resolved.DefaultNamespace.Connections[0].Config["password"])
+
+		assert.EqualError(t, WriteTenantConfig(dir, resolved), "refusing to write a tenant config with resolved secrets to tenant.json")
+		assert.NoError(t, WriteTenantConfig(dir, ctx.TenantConfig))
+	})
+}
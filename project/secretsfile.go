@@ -0,0 +1,244 @@
+package project
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+
+	"github.com/suborbital/subo/subo/util"
+)
+
+// SecretsFileName is the name of the project's encrypted secrets file.
+const SecretsFileName = "Secrets.yaml"
+
+// SecretsKeyEnv is the environment variable that can hold the base64-encoded key that Secrets.yaml is encrypted with.
+const SecretsKeyEnv = "SUBO_SECRETS_KEY"
+
+// secretsKeySize is the size of the AES-256 key that Secrets.yaml is encrypted with.
+const secretsKeySize = 32
+
+// SecretsFile is a project's Secrets.yaml, which maps the names of secrets to their values encrypted with AES-GCM.
+// It can be committed as long as the key (from $SUBO_SECRETS_KEY or subo/secrets.key in the user's config directory) isn't.
+type SecretsFile struct {
+	path    string
+	secrets map[string]string
+}
+
+// OpenSecretsFile reads the project's Secrets.yaml, returning an empty SecretsFile if there isn't one.
+func OpenSecretsFile(cwd string) (*SecretsFile, error) {
+	s := &SecretsFile{
+		path:    filepath.Join(cwd, SecretsFileName),
+		secrets: map[string]string{},
+	}
+
+	fileBytes, err := ioutil.ReadFile(s.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return s, nil
+		}
+
+		return nil, errors.Wrapf(err, "failed to ReadFile for %s", SecretsFileName)
+	}
+
+	if err := yaml.UnmarshalStrict(fileBytes, &s.secrets); err != nil {
+		return nil, errors.Wrapf(err, "failed to Unmarshal %s", SecretsFileName)
+	}
+
+	return s, nil
+}
+
+// Names returns the names of the secrets in the file in alphabetical order.
+func (s *SecretsFile) Names() []string {
+	names := make([]string, 0, len(s.secrets))
+	for name := range s.secrets {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+// Secret decrypts the named secret, returning false if the file doesn't have it.
+func (s *SecretsFile) Secret(name string) (string, bool, error) {
+	encrypted, exists := s.secrets[name]
+	if !exists {
+		return "", false, nil
+	}
+
+	key, err := secretsKey()
+	if err != nil {
+		return "", false, err
+	}
+
+	sealed, err := base64.StdEncoding.DecodeString(encrypted)
+	if err != nil {
+		return "", false, errors.Wrapf(err, "secret %s in %s is not valid base64", name, SecretsFileName)
+	}
+
+	gcm, err := newSecretsCipher(key)
+	if err != nil {
+		return "", false, err
+	}
+
+	if len(sealed) < gcm.NonceSize() {
+		return "", false, fmt.Errorf("secret %s in %s is truncated", name, SecretsFileName)
+	}
+
+	// The secret's name is authenticated along with its value, so values can't be swapped between names.
+	plain, err := gcm.Open(nil, sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():], []byte(name))
+	if err != nil {
+		return "", false, fmt.Errorf("failed to decrypt secret %s (is the secrets key correct?)", name)
+	}
+
+	return string(plain), true, nil
+}
+
+// Set encrypts a secret's value and adds it to the file, replacing any existing value. The secrets key must already exist.
+func (s *SecretsFile) Set(name, value string) error {
+	if !secretNameRegex.MatchString(name) {
+		return fmt.Errorf("%q is not a valid secret name", name)
+	}
+
+	key, err := secretsKey()
+	if err != nil {
+		return err
+	}
+
+	gcm, err := newSecretsCipher(key)
+	if err != nil {
+		return err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return errors.Wrap(err, "failed to generate nonce")
+	}
+
+	sealed := gcm.Seal(nonce, nonce, []byte(value), []byte(name))
+	s.secrets[name] = base64.StdEncoding.EncodeToString(sealed)
+
+	return nil
+}
+
+// Remove removes a secret from the file, returning false if it didn't have it.
+func (s *SecretsFile) Remove(name string) bool {
+	if _, exists := s.secrets[name]; !exists {
+		return false
+	}
+
+	delete(s.secrets, name)
+
+	return true
+}
+
+// Write writes the file to disk.
+func (s *SecretsFile) Write() error {
+	fileBytes, err := yaml.Marshal(s.secrets)
+	if err != nil {
+		return errors.Wrap(err, "failed to Marshal")
+	}
+
+	if err := ioutil.WriteFile(s.path, fileBytes, util.PermFile); err != nil {
+		return errors.Wrap(err, "failed to WriteFile")
+	}
+
+	return nil
+}
+
+// SecretsKeyPath returns the path of the user's secrets key file.
+func SecretsKeyPath() (string, error) {
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return "", errors.Wrap(err, "failed to UserConfigDir")
+	}
+
+	return filepath.Join(configDir, "subo", "secrets.key"), nil
+}
+
+// EnsureSecretsKey generates the user's secrets key file if there is no key in it or in $SUBO_SECRETS_KEY,
+// returning the path of the file and whether it was created.
+func EnsureSecretsKey() (string, bool, error) {
+	if _, exists := os.LookupEnv(SecretsKeyEnv); exists {
+		return "", false, nil
+	}
+
+	keyPath, err := SecretsKeyPath()
+	if err != nil {
+		return "", false, errors.Wrap(err, "failed to SecretsKeyPath")
+	}
+
+	if _, err := os.Stat(keyPath); err == nil {
+		return keyPath, false, nil
+	} else if !os.IsNotExist(err) {
+		return "", false, errors.Wrap(err, "failed to Stat secrets key")
+	}
+
+	key := make([]byte, secretsKeySize)
+	if _, err := rand.Read(key); err != nil {
+		return "", false, errors.Wrap(err, "failed to generate secrets key")
+	}
+
+	if err := os.MkdirAll(filepath.Dir(keyPath), util.PermDirectoryPrivate); err != nil {
+		return "", false, errors.Wrap(err, "failed to MkdirAll")
+	}
+
+	if err := ioutil.WriteFile(keyPath, []byte(base64.StdEncoding.EncodeToString(key)+"\n"), util.PermFilePrivate); err != nil {
+		return "", false, errors.Wrap(err, "failed to WriteFile")
+	}
+
+	return keyPath, true, nil
+}
+
+// secretsKey returns the key that Secrets.yaml is encrypted with, from $SUBO_SECRETS_KEY or the user's secrets key file.
+func secretsKey() ([]byte, error) {
+	encoded, exists := os.LookupEnv(SecretsKeyEnv)
+	if !exists {
+		keyPath, err := SecretsKeyPath()
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to SecretsKeyPath")
+		}
+
+		keyBytes, err := ioutil.ReadFile(keyPath)
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil, fmt.Errorf("no secrets key found (set %s or add a secret with `subo secret set` to create %s)", SecretsKeyEnv, keyPath)
+			}
+
+			return nil, errors.Wrap(err, "failed to ReadFile for secrets key")
+		}
+
+		encoded = string(keyBytes)
+	}
+
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+	if err != nil || len(key) != secretsKeySize {
+		return nil, fmt.Errorf("the secrets key must be %d base64-encoded bytes", secretsKeySize)
+	}
+
+	return key, nil
+}
+
+func newSecretsCipher(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to NewCipher")
+	}
+
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to NewGCM")
+	}
+
+	return gcm, nil
+}
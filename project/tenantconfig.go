@@ -3,6 +3,7 @@ package project
 import (
 	"crypto/sha256"
 	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -17,8 +18,14 @@ import (
 	"github.com/suborbital/systemspec/tenant"
 )
 
-// WriteTenantConfig writes a tenant config to disk.
+// WriteTenantConfig writes a tenant config to disk. Configs with the ${ENV_VAR} and secret:// references in the
+// project's connections resolved (such as those returned by Context.ResolvedTenantConfig) are refused, so that
+// secrets are never written to tenant.json.
 func WriteTenantConfig(cwd string, cfg *tenant.Config) error {
+	if hasResolvedReferences(cwd, cfg) {
+		return errors.New("refusing to write a tenant config with resolved secrets to tenant.json")
+	}
+
 	filePath := filepath.Join(cwd, "tenant.json")
 
 	configBytes, err := cfg.Marshal()
@@ -33,6 +40,21 @@ func WriteTenantConfig(cwd string, cfg *tenant.Config) error {
 	return nil
 }
 
+// copyTenantConfig returns a deep copy of a tenant config.
+func copyTenantConfig(cfg *tenant.Config) (*tenant.Config, error) {
+	configJSON, err := json.Marshal(cfg)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to Marshal tenant config")
+	}
+
+	copied := &tenant.Config{}
+	if err := copied.Unmarshal(configJSON); err != nil {
+		return nil, errors.Wrap(err, "failed to Unmarshal tenant config")
+	}
+
+	return copied, nil
+}
+
 // readTenantConfig finds a tenant.json from disk but does not validate it.
 func readTenantConfig(cwd string) (*tenant.Config, error) {
 	filePath := filepath.Join(cwd, "tenant.json")
@@ -50,9 +72,14 @@ func readTenantConfig(cwd string) (*tenant.Config, error) {
 	return t, nil
 }
 
-// readQueriesFile finds a queries.yaml from disk.
-func readQueriesFile(cwd string) ([]tenant.DBQuery, error) {
-	filePath := filepath.Join(cwd, "Queries.yaml")
+// namespaceFiles are the files that hold the queries (Queries.yaml) and connections (Connections.yaml) of the
+// tenant's namespaces separately from tenant.json. They have the same format as a YAML tenant config, with the
+// default namespace under defaultNamespace and any others listed by name under namespaces.
+var namespaceFiles = []string{"Queries.yaml", "Connections.yaml"}
+
+// readNamespaceFile reads Queries.yaml or Connections.yaml from disk, returning nil if it doesn't exist.
+func readNamespaceFile(cwd, file string) (*tenant.Config, error) {
+	filePath := filepath.Join(cwd, file)
 
 	configBytes, err := ioutil.ReadFile(filePath)
 	if err != nil {
@@ -60,36 +87,83 @@ func readQueriesFile(cwd string) ([]tenant.DBQuery, error) {
 			return nil, nil
 		}
 
-		return nil, errors.Wrap(err, "failed to ReadFile for Queries.yaml")
+		return nil, errors.Wrapf(err, "failed to ReadFile for %s", file)
 	}
 
 	t := &tenant.Config{}
 	if err := t.UnmarshalYaml(configBytes); err != nil {
-		return nil, errors.Wrap(err, "failed to Unmarshal Directive")
+		return nil, errors.Wrapf(err, "failed to Unmarshal %s", file)
 	}
 
-	return t.DefaultNamespace.Queries, nil
+	return t, nil
 }
 
-// readConnectionsFile finds a queries.yaml from disk.
-func readConnectionsFile(cwd string) ([]tenant.Connection, error) {
-	filePath := filepath.Join(cwd, "Connections.yaml")
+// applyNamespaceFiles merges Queries.yaml and Connections.yaml into the tenant config, returning an error for each
+// of them that can't be.
+func applyNamespaceFiles(cwd string, cfg *tenant.Config) []error {
+	errs := []error{}
 
-	configBytes, err := ioutil.ReadFile(filePath)
-	if err != nil {
-		if os.IsNotExist(err) {
-			return nil, nil
+	for _, file := range namespaceFiles {
+		fileConfig, err := readNamespaceFile(cwd, file)
+		if err != nil {
+			errs = append(errs, errors.Wrap(&FileError{File: file, Err: err}, "failed to readNamespaceFile"))
+			continue
+		} else if fileConfig == nil {
+			continue
 		}
 
-		return nil, errors.Wrap(err, "failed to ReadFile for Queries.yaml")
+		if cfg == nil {
+			errs = append(errs, &NamespaceFileError{File: file})
+			continue
+		}
+
+		if missing := mergeNamespaceFile(cfg, file, fileConfig); len(missing) > 0 {
+			errs = append(errs, &NamespaceFileError{File: file, Missing: missing})
+		}
 	}
 
-	t := &tenant.Config{}
-	if err := t.UnmarshalYaml(configBytes); err != nil {
-		return nil, errors.Wrap(err, "failed to Unmarshal Directive")
+	return errs
+}
+
+// mergeNamespaceFile replaces the queries (for Queries.yaml) or connections (for Connections.yaml) of each of the
+// tenant config's namespaces with those listed for it in the file. It returns the names of any namespaces in the
+// file that the tenant config doesn't have.
+func mergeNamespaceFile(cfg *tenant.Config, file string, fileConfig *tenant.Config) []string {
+	missing := []string{}
+
+	merge := func(target *tenant.NamespaceConfig, from tenant.NamespaceConfig) {
+		if file == "Queries.yaml" && len(from.Queries) > 0 {
+			target.Queries = from.Queries
+		} else if file == "Connections.yaml" && len(from.Connections) > 0 {
+			target.Connections = from.Connections
+		}
+	}
+
+	merge(&cfg.DefaultNamespace, fileConfig.DefaultNamespace)
+
+	for _, ns := range fileConfig.Namespaces {
+		if ns.Name == cfg.DefaultNamespace.Name {
+			merge(&cfg.DefaultNamespace, ns)
+			continue
+		}
+
+		found := false
+
+		for i := range cfg.Namespaces {
+			if cfg.Namespaces[i].Name == ns.Name {
+				merge(&cfg.Namespaces[i], ns)
+				found = true
+
+				break
+			}
+		}
+
+		if !found {
+			missing = append(missing, ns.Name)
+		}
 	}
 
-	return t.DefaultNamespace.Connections, nil
+	return missing
 }
 
 // CalculateModuleRefs calculates the hash refs for all modules and validates correctness of the config.
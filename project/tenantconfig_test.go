@@ -0,0 +1,85 @@
+package project
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testNamespacesTenantJSON = `{
+	"identifier": "com.suborbital.app",
+	"specVersion": 1,
+	"tenantVersion": 1,
+	"defaultNamespace": {"name": "default"},
+	"namespaces": [{"name": "billing"}, {"name": "users"}]
+}`
+
+const testNamespacesQueries = `defaultNamespace:
+  queries:
+    - name: PGGetUser
+      query: SELECT * FROM users WHERE uuid = $1
+namespaces:
+  - name: billing
+    queries:
+      - name: PGGetInvoice
+        query: SELECT * FROM invoices WHERE uuid = $1
+`
+
+const testNamespacesConnections = `namespaces:
+  - name: billing
+    connections:
+      - type: postgres
+        name: billing-db
+        config:
+          connectionString: postgresql://billing
+  - name: default
+    connections:
+      - type: redis
+        name: cache
+        config:
+          serverAddress: redis://localhost:6379
+`
+
+func TestForDirectory_NamespaceFiles(t *testing.T) {
+	dir := t.TempDir()
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "tenant.json"), []byte(testNamespacesTenantJSON), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "Queries.yaml"), []byte(testNamespacesQueries), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "Connections.yaml"), []byte(testNamespacesConnections), 0644))
+
+	t.Run("merges each namespace's queries and connections", func(t *testing.T) {
+		ctx, err := ForDirectory(dir)
+		require.NoError(t, err)
+
+		cfg := ctx.TenantConfig
+
+		require.Len(t, cfg.DefaultNamespace.Queries, 1)
+		assert.Equal(t, "PGGetUser", cfg.DefaultNamespace.Queries[0].Name)
+		require.Len(t, cfg.DefaultNamespace.Connections, 1)
+		assert.Equal(t, "cache", cfg.DefaultNamespace.Connections[0].Name)
+
+		billing := cfg.Namespaces[0]
+		require.Len(t, billing.Queries, 1)
+		assert.Equal(t, "PGGetInvoice", billing.Queries[0].Name)
+		require.Len(t, billing.Connections, 1)
+		assert.Equal(t, "billing-db", billing.Connections[0].Name)
+
+		assert.Empty(t, cfg.Namespaces[1].Queries)
+		assert.Empty(t, cfg.Namespaces[1].Connections)
+	})
+
+	t.Run("reports namespaces that don't exist", func(t *testing.T) {
+		queries := testNamespacesQueries + "  - name: shipping\n    queries: []\n"
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "Queries.yaml"), []byte(queries), 0644))
+
+		_, err := ForDirectory(dir)
+		assert.EqualError(t, err, "failed to applyNamespaceFiles: Queries.yaml refers to namespaces that don't exist in tenant.json: shipping")
+
+		problems, err := Validate(dir, "")
+		require.NoError(t, err)
+		assert.Contains(t, problems, Problem{Rule: RuleProject, File: "Queries.yaml", Line: 10, Message: "namespace shipping does not exist in tenant.json"})
+	})
+}
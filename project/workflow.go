@@ -0,0 +1,99 @@
+package project
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/suborbital/systemspec/tenant"
+)
+
+// ModuleFQMN returns the FQMN that a workflow in namespace (the default namespace if it's empty) uses to refer to
+// the named module. It has no ref, as refs are only calculated when the module is built. The name can be qualified
+// with the module's namespace (namespace/name), otherwise the workflow's own namespace is used if it has a module
+// with that name, or failing that the only module in any namespace with that name.
+func (b *Context) ModuleFQMN(namespace, name string) (string, error) {
+	if b.TenantConfig == nil {
+		return "", errors.New("no tenant.json found")
+	}
+
+	if namespace == "" {
+		namespace = b.TenantConfig.DefaultNamespace.Name
+	}
+
+	modNamespace, modName, qualified := strings.Cut(name, "/")
+	if !qualified {
+		modNamespace, modName = "", name
+	}
+
+	matches := []ModuleDir{}
+
+	for _, m := range b.Modules {
+		if m.Name != modName || (qualified && m.namespace() != modNamespace) {
+			continue
+		}
+
+		if !qualified && m.namespace() == namespace {
+			matches = []ModuleDir{m}
+			break
+		}
+
+		matches = append(matches, m)
+	}
+
+	switch len(matches) {
+	case 0:
+		return "", fmt.Errorf("module %s does not exist", name)
+	case 1:
+		m := matches[0]
+		return fmt.Sprintf("fqmn://%s/%s/%s", b.TenantConfig.Identifier, m.namespace(), m.Name), nil
+	}
+
+	namespaces := make([]string, len(matches))
+	for i, m := range matches {
+		namespaces[i] = m.namespace()
+	}
+
+	return "", fmt.Errorf("module %s exists in namespaces %s, use namespace/%s to choose one", name, strings.Join(namespaces, ", "), name)
+}
+
+// AddWorkflow adds a workflow to a namespace (the default namespace if it's empty) of the tenant.json in cwd.
+// Only tenant.json itself is read and written, so Queries.yaml, Connections.yaml and secrets aren't merged into it.
+func AddWorkflow(cwd, namespace string, workflow tenant.Workflow) error {
+	cfg, err := readTenantConfig(cwd)
+	if err != nil {
+		return errors.Wrap(err, "failed to readTenantConfig")
+	}
+
+	var ns *tenant.NamespaceConfig
+
+	if namespace == "" || namespace == cfg.DefaultNamespace.Name {
+		ns = &cfg.DefaultNamespace
+	} else {
+		for i := range cfg.Namespaces {
+			if cfg.Namespaces[i].Name == namespace {
+				ns = &cfg.Namespaces[i]
+				break
+			}
+		}
+	}
+
+	if ns == nil {
+		return fmt.Errorf("namespace %s does not exist in tenant.json", namespace)
+	}
+
+	for _, w := range ns.Workflows {
+		if w.Name == workflow.Name {
+			return fmt.Errorf("workflow %s already exists in namespace %s", workflow.Name, ns.Name)
+		}
+	}
+
+	ns.Workflows = append(ns.Workflows, workflow)
+
+	if err := WriteTenantConfig(cwd, cfg); err != nil {
+		return errors.Wrap(err, "failed to WriteTenantConfig")
+	}
+
+	return nil
+}
@@ -0,0 +1,88 @@
+package project
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/suborbital/systemspec/tenant"
+	"github.com/suborbital/systemspec/tenant/executable"
+)
+
+func TestModuleFQMN(t *testing.T) {
+	dir := t.TempDir()
+
+	writeTestModule(t, dir, "hello", "hello")
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "users", "hello"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "users", "hello", ".module.yaml"), []byte("name: hello\nnamespace: users\nlang: wat\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "tenant.json"), []byte(testNamespacesTenantJSON), 0644))
+
+	ctx, err := ForDirectory(dir)
+	require.NoError(t, err)
+
+	FQMN, err := ctx.ModuleFQMN("", "hello")
+	require.NoError(t, err)
+	assert.Equal(t, "fqmn://com.suborbital.app/default/hello", FQMN)
+
+	FQMN, err = ctx.ModuleFQMN("users", "hello")
+	require.NoError(t, err)
+	assert.Equal(t, "fqmn://com.suborbital.app/users/hello", FQMN)
+
+	FQMN, err = ctx.ModuleFQMN("billing", "users/hello")
+	require.NoError(t, err)
+	assert.Equal(t, "fqmn://com.suborbital.app/users/hello", FQMN)
+
+	_, err = ctx.ModuleFQMN("billing", "hello")
+	assert.EqualError(t, err, "module hello exists in namespaces default, users, use namespace/hello to choose one")
+
+	_, err = ctx.ModuleFQMN("", "billing/hello")
+	assert.EqualError(t, err, "module billing/hello does not exist")
+}
+
+func TestAddWorkflow(t *testing.T) {
+	dir := t.TempDir()
+
+	writeTestModule(t, dir, "hello", "hello")
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "tenant.json"), []byte(testNamespacesTenantJSON), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "Queries.yaml"), []byte(testNamespacesQueries), 0644))
+
+	ctx, err := ForDirectory(dir)
+	require.NoError(t, err)
+
+	FQMN, err := ctx.ModuleFQMN("", "hello")
+	require.NoError(t, err)
+	assert.Equal(t, "fqmn://com.suborbital.app/default/hello", FQMN)
+
+	_, err = ctx.ModuleFQMN("", "nope")
+	assert.EqualError(t, err, "module nope does not exist")
+
+	workflow := tenant.Workflow{
+		Name:  "greet",
+		Steps: []executable.Executable{{ExecutableMod: executable.ExecutableMod{FQMN: FQMN}}},
+	}
+
+	require.NoError(t, AddWorkflow(dir, "", workflow))
+	require.NoError(t, AddWorkflow(dir, "billing", workflow))
+
+	t.Run("writes the workflow to tenant.json only", func(t *testing.T) {
+		cfg, err := readTenantConfig(dir)
+		require.NoError(t, err)
+
+		require.Len(t, cfg.DefaultNamespace.Workflows, 1)
+		assert.Equal(t, FQMN, cfg.DefaultNamespace.Workflows[0].Steps[0].FQMN)
+		assert.Empty(t, cfg.DefaultNamespace.Queries)
+		require.Len(t, cfg.Namespaces[0].Workflows, 1)
+
+		problems, err := Validate(dir, "")
+		require.NoError(t, err)
+		assert.Empty(t, problems)
+	})
+
+	t.Run("rejects duplicate workflows and missing namespaces", func(t *testing.T) {
+		assert.EqualError(t, AddWorkflow(dir, "default", workflow), "workflow greet already exists in namespace default")
+		assert.EqualError(t, AddWorkflow(dir, "shipping", workflow), "namespace shipping does not exist in tenant.json")
+	})
+}
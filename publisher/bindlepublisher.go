@@ -66,8 +66,13 @@ func (b *BindlePublishJob) Publish(log util.FriendlyLogger, ctx *project.Context
 
 	parcelsBySHA := map[string]parcelWrapper{}
 
+	config, err := ctx.ResolvedTenantConfig()
+	if err != nil {
+		return errors.Wrap(err, "failed to ResolvedTenantConfig")
+	}
+
 	// add the Directive as a parcel.
-	configBytes, err := yaml.Marshal(ctx.TenantConfig)
+	configBytes, err := yaml.Marshal(config)
 	if err != nil {
 		return errors.Wrap(err, "failed to Marshal Directive")
 	}
@@ -42,8 +42,13 @@ func (b *DockerPublishJob) Publish(log util.FriendlyLogger, ctx *project.Context
 		return errors.Wrap(err, "failed to DockerNameFromConfig")
 	}
 
-	if _, err := util.Command.Run(fmt.Sprintf("docker buildx build . --platform linux/amd64,linux/arm64 -t %s --push", imageName)); err != nil {
-		return errors.Wrap(err, "failed to Run docker")
+	engine, err := util.Engine()
+	if err != nil {
+		return errors.Wrap(err, "failed to util.Engine")
+	}
+
+	if _, err := util.Command.Run(engine.MultiPlatformPushCommand(imageName)); err != nil {
+		return errors.Wrapf(err, "failed to Run %s", engine.Name)
 	}
 
 	util.LogDone(fmt.Sprintf("pushed Docker image -> %s", imageName))
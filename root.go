@@ -6,6 +6,7 @@ import (
 	"github.com/suborbital/subo/subo/command"
 	"github.com/suborbital/subo/subo/features"
 	"github.com/suborbital/subo/subo/release"
+	"github.com/suborbital/subo/subo/util"
 )
 
 func rootCommand() *cobra.Command {
@@ -22,11 +23,18 @@ func rootCommand() *cobra.Command {
 
 	cmd.SetVersionTemplate("Subo CLI v{{.Version}}\n")
 
+	cmd.PersistentFlags().String("engine", "", "the container engine to use (docker, podman or nerdctl), auto-detected if not set")
+	cmd.PersistentPreRunE = func(cmd *cobra.Command, args []string) error {
+		engine, _ := cmd.Flags().GetString("engine")
+
+		return util.SetContainerEngine(engine)
+	}
+
 	// create commands.
 	create := &cobra.Command{
 		Use:   "create",
-		Short: "create a plugin, project, or handler",
-		Long:  `create a new E2Core project, WebAssembly plugin or handler`,
+		Short: "create a plugin, project, or workflow",
+		Long:  `create a new E2Core project, WebAssembly plugin or workflow`,
 	}
 
 	if features.EnableReleaseCommands {
@@ -35,9 +43,7 @@ func rootCommand() *cobra.Command {
 
 	create.AddCommand(command.CreateProjectCmd())
 	create.AddCommand(command.CreatePluginCmd())
-	// TODO: turn into create workflow command
-	// Ref: https://github.com/suborbital/subo/issues/347
-	// create.AddCommand(command.CreateHandlerCmd()).
+	create.AddCommand(command.CreateWorkflowCmd())
 
 	// se2 related commands.
 	cmd.AddCommand(se2Command())
@@ -45,8 +51,17 @@ func rootCommand() *cobra.Command {
 	// docs related commands.
 	cmd.AddCommand(docsCommand())
 
+	// secret related commands.
+	cmd.AddCommand(secretCommand())
+
+	// workflow related commands.
+	cmd.AddCommand(workflowsCommand())
+
 	cmd.AddCommand(create)
 	cmd.AddCommand(command.BuildCmd())
+	cmd.AddCommand(command.InspectCmd())
+	cmd.AddCommand(command.ConfigCmd())
+	cmd.AddCommand(command.ValidateCmd())
 
 	// TODO: Re-enable when dev is updated to work with e2core
 	// cmd.AddCommand(command.DevCmd())
@@ -94,3 +109,27 @@ func docsCommand() *cobra.Command {
 
 	return docs
 }
+
+func secretCommand() *cobra.Command {
+	secret := &cobra.Command{
+		Use:   "secret",
+		Short: "manage project secrets",
+		Long:  "manage the encrypted secrets in the project's Secrets.yaml that connections refer to as secret://<name>",
+	}
+	secret.AddCommand(command.SecretSetCmd())
+	secret.AddCommand(command.SecretListCmd())
+	secret.AddCommand(command.SecretRemoveCmd())
+
+	return secret
+}
+
+func workflowsCommand() *cobra.Command {
+	workflows := &cobra.Command{
+		Use:   "workflows",
+		Short: "workflow related resources",
+		Long:  "inspect the workflows in the project's tenant.json",
+	}
+	workflows.AddCommand(command.WorkflowsGraphCmd())
+
+	return workflows
+}
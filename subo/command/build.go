@@ -1,13 +1,18 @@
 package command
 
 import (
+	"context"
 	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
 
 	"github.com/suborbital/subo/builder"
 	"github.com/suborbital/subo/packager"
+	"github.com/suborbital/subo/project"
 	"github.com/suborbital/subo/subo/util"
 )
 
@@ -23,17 +28,77 @@ func BuildCmd() *cobra.Command {
 				dir = args[0]
 			}
 
-			bdr, err := builder.ForDirectory(&util.PrintLogger{}, &builder.DefaultBuildConfig, dir)
+			bctx, err := project.ForDirectory(dir)
 			if err != nil {
-				return errors.Wrap(err, "failed to builder.ForDirectory")
+				return errors.Wrap(err, "🚫 failed to project.ForDirectory")
 			}
 
+			// Flags that weren't passed are filled in from the environment, subo.yaml and the user's config.
+			if err := applySettings(cmd, bctx.Defaults, "build"); err != nil {
+				return errors.Wrap(err, "🚫 failed to applySettings")
+			}
+
+			config := builder.DefaultBuildConfig
+			config.JsToolchain, _ = cmd.Flags().GetString("js-toolchain")
+
+			var logger util.FriendlyLogger = &util.PrintLogger{}
+
+			reportFormat, _ := cmd.Flags().GetString("output")
+			reportFile, _ := cmd.Flags().GetString("output-file")
+
+			if reportFormat != "" {
+				if reportFormat != string(builder.ReportFormatJSON) && reportFormat != string(builder.ReportFormatJUnit) {
+					return fmt.Errorf("🚫 %s is not a valid output format (json or junit)", reportFormat)
+				}
+
+				if reportFile == "" {
+					// The report is written to stdout, so keep everything else out of it.
+					// Command output is still available in the report itself.
+					logger = &util.StderrLogger{}
+					config.CommandRunner = util.NewCommandLineExecutor(util.SilentOutput, nil)
+				}
+			}
+
+			jobs, _ := cmd.Flags().GetInt("jobs")
+			if jobs > 1 && reportFormat == "" {
+				// Output from parallel builds would be interleaved, so it is collected
+				// silently and only printed for modules that fail.
+				config.CommandRunner = util.NewCommandLineExecutor(util.SilentOutput, nil)
+			}
+
+			config.Jobs = jobs
+
+			if noCache, _ := cmd.Flags().GetBool("no-cache"); noCache {
+				config.UseCache = false
+			}
+
+			if noValidate, _ := cmd.Flags().GetBool("no-validate"); noValidate {
+				config.ValidateWasm = false
+			}
+
+			config.Timeout, _ = cmd.Flags().GetDuration("timeout")
+			config.Optimize, _ = cmd.Flags().GetBool("optimize")
+			config.Offline, _ = cmd.Flags().GetBool("offline")
+			config.MirrorDir, _ = cmd.Flags().GetString("mirror")
+
+			config.EmbedMetadata, _ = cmd.Flags().GetBool("metadata")
+			config.APIVersion, _ = cmd.Flags().GetString("api-version")
+
+			bdr := builder.ForContext(logger, &config, bctx)
+
 			if len(bdr.Context.Modules) == 0 {
 				return errors.New("🚫 no modules found in current directory (no .module.yaml files found)")
 			}
 
 			if bdr.Context.CwdIsModule {
-				util.LogInfo("building single module (run from project root to create bundle)")
+				logger.LogInfo("building single module (run from project root to create bundle)")
+			}
+
+			// The environment's overlay only matters for packaging, which single modules don't do.
+			if env, _ := cmd.Flags().GetString("env"); env != "" && !bdr.Context.CwdIsModule {
+				if err := bdr.Context.ApplyEnvironment(env); err != nil {
+					return errors.Wrap(err, "🚫 failed to ApplyEnvironment")
+				}
 			}
 
 			langs, _ := cmd.Flags().GetStringSlice("langs")
@@ -70,8 +135,8 @@ func BuildCmd() *cobra.Command {
 			}
 
 			if makeTarget != "" {
-				util.LogStart(fmt.Sprintf("make %s", makeTarget))
-				_, err = util.Command.Run(fmt.Sprintf("make %s", makeTarget))
+				logger.LogStart(fmt.Sprintf("make %s", makeTarget))
+				_, err = config.CommandRunner.Run(fmt.Sprintf("make %s", makeTarget))
 				if err != nil {
 					return errors.Wrapf(err, "🚫 failed to make %s", makeTarget)
 				}
@@ -81,16 +146,55 @@ func BuildCmd() *cobra.Command {
 			if useNative {
 				toolchain = builder.ToolchainNative
 			} else {
-				util.LogInfo("🐳 using Docker toolchain")
+				logger.LogInfo("🐳 using Docker toolchain")
 				toolchain = builder.ToolchainDocker
 			}
 
+			mods := bdr.Context.Modules
+
+			if since, _ := cmd.Flags().GetString("since"); since != "" {
+				// Unchanged modules are left alone and their existing .wasm files are used for the bundle.
+				mods, err = bdr.Context.ModulesChangedSince(since)
+				if err != nil {
+					return errors.Wrap(err, "🚫 failed to ModulesChangedSince")
+				}
+
+				logger.LogInfo(fmt.Sprintf("building %d of %d modules changed since %s", len(mods), len(bdr.Context.Modules), since))
+			}
+
+			// Interrupting subo stops the running builds (and their containers) rather than leaving them behind.
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+
+			if matrixFlags, _ := cmd.Flags().GetStringSlice("matrix"); len(matrixFlags) > 0 {
+				return runBuildMatrix(ctx, cmd, bdr, toolchain, mods, matrixFlags)
+			}
+
 			// The builder does the majority of the work.
-			if err := bdr.BuildWithToolchain(toolchain); err != nil {
-				return errors.Wrap(err, "failed to BuildWithToolchain")
+			buildErr := bdr.BuildModulesWithToolchain(ctx, toolchain, mods)
+
+			if reportFormat != "" {
+				if err := writeBuildReport(bdr, builder.ReportFormat(reportFormat), reportFile); err != nil {
+					return errors.Wrap(err, "failed to writeBuildReport")
+				}
+			}
+
+			watch, _ := cmd.Flags().GetBool("watch")
+
+			if buildErr != nil {
+				if jobs > 1 && reportFormat == "" {
+					printFailedBuildLogs(bdr)
+				}
+
+				if !watch || ctx.Err() != nil {
+					return errors.Wrap(buildErr, "failed to BuildWithToolchain")
+				}
+
+				// In watch mode a failed build is expected to be fixed by the next change.
+				logger.LogFail(buildErr.Error())
 			}
 
-			pkgr := packager.New(&util.PrintLogger{})
+			pkgr := packager.New(logger)
 			pkgJobs := []packager.PackageJob{}
 
 			if shouldBundle {
@@ -101,8 +205,29 @@ func BuildCmd() *cobra.Command {
 				pkgJobs = append(pkgJobs, packager.NewDockerImagePackageJob())
 			}
 
-			if err := pkgr.Package(bdr.Context, pkgJobs...); err != nil {
-				return errors.Wrap(err, "failed to Package")
+			if buildErr == nil {
+				if !watch {
+					// Packaging can't be cancelled, so Ctrl-C goes back to interrupting subo and the commands
+					// (such as docker build) that it runs in the foreground.
+					stop()
+				}
+
+				if err := pkgr.Package(bdr.Context, pkgJobs...); err != nil {
+					return errors.Wrap(err, "failed to Package")
+				}
+			}
+
+			if watch {
+				var rebundle func() error
+				if shouldBundle {
+					rebundle = func() error {
+						return pkgr.Package(bdr.Context, packager.NewBundlePackageJob())
+					}
+				}
+
+				if err := builder.NewWatcher(bdr, toolchain).Watch(ctx, rebundle); err != nil {
+					return errors.Wrap(err, "failed to Watch")
+				}
 			}
 
 			return nil
@@ -113,10 +238,108 @@ func BuildCmd() *cobra.Command {
 	cmd.Flags().Bool("native", false, "use native (locally installed) toolchain rather than Docker")
 	cmd.Flags().String("make", "", "execute the provided Make target before building the project bundle")
 	cmd.Flags().Bool("docker", false, "build your project's Dockerfile. It will be tagged {identifier}:{appVersion}")
+	cmd.Flags().String("env", "", "package the project with the tenant config overlay for the named environment (tenant.{env}.yaml) merged in")
 	cmd.Flags().StringSlice("langs", []string{}, "build only modules for the listed languages (comma-seperated)")
 	cmd.Flags().String("mountpath", "", "if passed, the Docker builders will mount their volumes at the provided path")
 	cmd.Flags().String("relpath", "", "if passed, the Docker builders will run `subo build` using the provided path, relative to '--mountpath'")
 	cmd.Flags().String("builder-tag", "", "use the provided tag for builder images")
+	cmd.Flags().String("js-toolchain", builder.DefaultBuildConfig.JsToolchain, "the package manager used to build JavaScript and TypeScript modules (npm or yarn)")
+	cmd.Flags().Bool("no-cache", false, "if passed, modules are always rebuilt rather than restored from the build cache")
+	cmd.Flags().Bool("no-validate", false, "if passed, built modules are not checked for the exports and imports required by their API version")
+	cmd.Flags().Bool("optimize", false, "strip custom sections (debug names etc) from built modules to reduce their size")
+	cmd.Flags().Bool("metadata", false, "embed build metadata (git commit, builder tag etc) into built modules, which changes their refs on every commit")
+	cmd.Flags().String("output", "", "write a machine-readable build report in the given format (json or junit)")
+	cmd.Flags().String("output-file", "", "if passed, the build report is written to the provided file rather than stdout")
+	cmd.Flags().String("since", "", "build only the modules that have changed since the provided git ref")
+	cmd.Flags().Bool("watch", false, "if passed, watch the project for changes and rebuild the modules that changed")
+	cmd.Flags().Bool("offline", false, "build without network access, acquiring prerequisites from the mirror directory or local package caches")
+	cmd.Flags().String("mirror", "", "the directory that prerequisites are copied from for offline builds (defaults to subo's cache directory)")
+	cmd.Flags().Duration("timeout", 0, "the longest each module's build may take (e.g. 10m), after which it is cancelled")
+	cmd.Flags().String("api-version", "", "build every module against the provided API version rather than the one in its .module.yaml")
+	cmd.Flags().StringSlice("matrix", []string{}, "build each module with every listed builder tag (tag=v0.6.0, Docker toolchain only) or API version (api=0.16.0) and print a compatibility table; bundles are not created")
+	cmd.Flags().Int("jobs", 1, "the number of modules to build at the same time")
 
 	return cmd
 }
+
+// writeBuildReport writes a report of the builder's results to the given file, or to stdout if no file is provided.
+func writeBuildReport(bdr *builder.Builder, format builder.ReportFormat, filename string) error {
+	// Results returns an error when nothing was built, which is reported as an empty list of modules.
+	results, _ := bdr.Results()
+
+	report := builder.NewBuildReport(results)
+
+	if filename == "" {
+		return report.Write(os.Stdout, format)
+	}
+
+	file, err := os.Create(filename)
+	if err != nil {
+		return errors.Wrap(err, "failed to Create report file")
+	}
+
+	defer file.Close()
+
+	if err := report.Write(file, format); err != nil {
+		return errors.Wrap(err, "failed to Write report")
+	}
+
+	return nil
+}
+
+// runBuildMatrix builds the modules with each matrix entry and prints a table of the results.
+func runBuildMatrix(ctx context.Context, cmd *cobra.Command, bdr *builder.Builder, toolchain builder.Toolchain, mods []project.ModuleDir, matrixFlags []string) error {
+	watch, _ := cmd.Flags().GetBool("watch")
+	reportFormat, _ := cmd.Flags().GetString("output")
+
+	if watch || reportFormat != "" {
+		return errors.New("🚫 --matrix cannot be used with --watch or --output")
+	}
+
+	entries := []builder.MatrixEntry{}
+
+	for _, f := range matrixFlags {
+		entry, err := builder.ParseMatrixEntry(f)
+		if err != nil {
+			return errors.Wrap(err, "🚫 failed to ParseMatrixEntry")
+		}
+
+		entries = append(entries, entry)
+	}
+
+	matrix, err := bdr.BuildMatrix(ctx, toolchain, mods, entries)
+	if err != nil {
+		return errors.Wrap(err, "🚫 failed to BuildMatrix")
+	}
+
+	fmt.Println()
+
+	if err := builder.WriteMatrixTable(os.Stdout, matrix); err != nil {
+		return errors.Wrap(err, "failed to WriteMatrixTable")
+	}
+
+	util.LogInfo(fmt.Sprintf("built modules are stored in %s", bdr.MatrixDir()))
+
+	if failures := builder.MatrixFailures(matrix); failures > 0 {
+		return fmt.Errorf("🚫 %d of %d matrix builds failed", failures, len(entries)*len(matrix[0].Results))
+	}
+
+	return nil
+}
+
+// printFailedBuildLogs prints the captured output of every module that failed to build.
+func printFailedBuildLogs(bdr *builder.Builder) {
+	results, err := bdr.Results()
+	if err != nil {
+		return
+	}
+
+	for _, r := range results {
+		if r.Succeeded {
+			continue
+		}
+
+		util.LogFail(fmt.Sprintf("output for %s:", r.Name))
+		fmt.Println(r.OutputLog)
+	}
+}
@@ -14,7 +14,7 @@ import (
 	"github.com/suborbital/subo/subo/util"
 )
 
-// CleanCmd  removes all of the target/.build folders for modules and deletes the .wasm files.
+// CleanCmd  removes all of the language build folders (target/.build etc) for modules and deletes the .wasm files.
 func CleanCmd() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "clean",
@@ -38,13 +38,20 @@ func CleanCmd() *cobra.Command {
 			util.LogStart(fmt.Sprintf("cleaning in %s", bctx.Cwd))
 
 			for _, r := range bctx.Modules {
-				// Delete target or .build folder.
+				cleanTargets := map[string]bool{}
+				if lang, exists := project.LanguageFor(r.Module.Lang); exists {
+					for _, t := range lang.CleanTargets {
+						cleanTargets[t] = true
+					}
+				}
+
+				// Delete the language's build folders (target, .build, etc).
 				files, _ := ioutil.ReadDir(r.Fullpath)
 
 				for _, file := range files {
 					fullPath := filepath.Join(r.Fullpath, file.Name())
 					if file.IsDir() {
-						if file.Name() == "target" || file.Name() == ".build" {
+						if cleanTargets[file.Name()] {
 							if rErr := os.RemoveAll(fullPath); rErr != nil {
 								util.LogFail(errors.Wrap(rErr, "failed to RemoveAll").Error())
 								continue
@@ -0,0 +1,86 @@
+package command
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v2"
+
+	"github.com/suborbital/subo/project"
+)
+
+// ConfigCmd returns the config command.
+func ConfigCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "config",
+		Short: "print the project's tenant config",
+		Long:  `print the project's tenant config, with Queries.yaml, Connections.yaml and an environment's overlay (tenant.{env}.yaml) merged in`,
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cwd, err := os.Getwd()
+			if err != nil {
+				return errors.Wrap(err, "failed to Getwd")
+			}
+
+			ctx, err := project.ForDirectory(cwd)
+			if err != nil {
+				return errors.Wrap(err, "failed to project.ForDirectory")
+			}
+
+			// Without --env, the same config that subo build packages is shown.
+			if err := applySettings(cmd, ctx.Defaults, "config"); err != nil {
+				return errors.Wrap(err, "🚫 failed to applySettings")
+			}
+
+			env, _ := cmd.Flags().GetString("env")
+
+			if ctx.TenantConfig == nil {
+				return errors.New("🚫 no tenant.json found in current directory")
+			}
+
+			if env != "" {
+				if err := ctx.ApplyEnvironment(env); err != nil {
+					return errors.Wrap(err, "🚫 failed to ApplyEnvironment")
+				}
+			}
+
+			// Secrets are only looked up and printed when asked for.
+			config := ctx.TenantConfig
+			if resolve, _ := cmd.Flags().GetBool("resolve"); resolve {
+				config, err = ctx.ResolvedTenantConfig()
+				if err != nil {
+					return errors.Wrap(err, "🚫 failed to ResolvedTenantConfig")
+				}
+			}
+
+			var configBytes []byte
+
+			if asYAML, _ := cmd.Flags().GetBool("yaml"); asYAML {
+				configBytes, err = yaml.Marshal(config)
+				if err != nil {
+					return errors.Wrap(err, "failed to Marshal")
+				}
+			} else {
+				configBytes, err = json.MarshalIndent(config, "", "  ")
+				if err != nil {
+					return errors.Wrap(err, "failed to MarshalIndent")
+				}
+
+				configBytes = append(configBytes, '\n')
+			}
+
+			fmt.Print(string(configBytes))
+
+			return nil
+		},
+	}
+
+	cmd.Flags().String("env", "", "merge the tenant config overlay for the named environment (tenant.{env}.yaml) into the printed config")
+	cmd.Flags().Bool("yaml", false, "print the config as YAML rather than JSON")
+	cmd.Flags().Bool("resolve", false, "print the config with the secrets and environment variables that its connections refer to resolved")
+
+	return cmd
+}
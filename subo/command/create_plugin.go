@@ -17,16 +17,6 @@ import (
 	"github.com/suborbital/systemspec/tenant"
 )
 
-// langAliases are aliases for languages.
-var langAliases = map[string]string{
-	"as": "assemblyscript",
-	"rs": "rust",
-	"go": "tinygo",
-	"gr": "grain",
-	"ts": "typescript",
-	"js": "javascript",
-}
-
 // CreatePluginError wraps errors for CreatePluginCmd() failures.
 type CreatePluginError struct {
 	Path  string // The ouput directory for build command CreatePluginCmd().
@@ -128,9 +118,7 @@ func CreatePluginCmd() *cobra.Command {
 }
 
 func writeDotModule(cwd, name, lang, namespace string) (*tenant.Module, error) {
-	if actual, exists := langAliases[lang]; exists {
-		lang = actual
-	}
+	lang = project.ResolveLangAlias(lang)
 
 	if valid := project.IsValidLang(lang); !valid {
 		return nil, fmt.Errorf("%s is not an available language", lang)
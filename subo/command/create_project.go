@@ -1,6 +1,7 @@
 package command
 
 import (
+	"context"
 	"fmt"
 	"os"
 
@@ -92,7 +93,7 @@ func CreateProjectCmd() *cobra.Command {
 
 			util.LogDone(path)
 
-			if _, err := util.Command.Run(fmt.Sprintf("git init ./%s", name)); err != nil {
+			if _, err := util.Command.Exec(context.Background(), util.ArgsCmd("git", "init", "./"+name)); err != nil {
 				return errors.Wrap(err, "🚫 failed to initialize Run git init")
 			}
 
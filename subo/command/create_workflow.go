@@ -0,0 +1,169 @@
+package command
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+
+	"github.com/suborbital/subo/project"
+	"github.com/suborbital/subo/subo/util"
+	"github.com/suborbital/systemspec/fqmn"
+	"github.com/suborbital/systemspec/tenant"
+	"github.com/suborbital/systemspec/tenant/executable"
+)
+
+// CreateWorkflowCmd returns the create workflow command.
+func CreateWorkflowCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "workflow <name>",
+		Short: "create a new workflow",
+		Long: `create a new workflow in tenant.json. Each --step runs a module, or a group of modules in parallel if
+several are given separated by commas (e.g. --step fetch-user --step get-avatar,get-posts --step render).
+Modules in other namespaces can be given as namespace/name`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			name := args[0]
+
+			namespace, _ := cmd.Flags().GetString(namespaceFlag)
+			stepSpecs, _ := cmd.Flags().GetStringArray(stepFlag)
+			triggerSpecs, _ := cmd.Flags().GetStringArray(triggerFlag)
+			response, _ := cmd.Flags().GetString(responseFlag)
+
+			dir, _ := cmd.Flags().GetString(dirFlag)
+			bctx, err := project.ForDirectory(dir)
+			if err != nil {
+				return errors.Wrap(err, "🚫 failed to project.ForDirectory")
+			}
+
+			if bctx.TenantConfig == nil {
+				return errors.New("🚫 cannot create workflow, tenant.json not found")
+			}
+
+			if len(stepSpecs) == 0 {
+				return errors.New("🚫 a workflow needs at least one --step")
+			}
+
+			workflow := tenant.Workflow{
+				Name:     name,
+				Steps:    []executable.Executable{},
+				Response: response,
+				Triggers: []tenant.Trigger{},
+			}
+
+			for _, spec := range stepSpecs {
+				step, err := parseWorkflowStep(bctx, namespace, spec)
+				if err != nil {
+					return errors.Wrap(err, "🚫 failed to parseWorkflowStep")
+				}
+
+				workflow.Steps = append(workflow.Steps, step)
+			}
+
+			// Results are stored under their module's FQMN, which changes with its ref when it's built, so a module
+			// that the workflow responds with stores its result under its name instead.
+			if response != "" {
+				setWorkflowResponseKey(workflow.Steps, response)
+			}
+
+			for _, spec := range triggerSpecs {
+				trigger, err := parseWorkflowTrigger(spec)
+				if err != nil {
+					return errors.Wrap(err, "🚫 failed to parseWorkflowTrigger")
+				}
+
+				workflow.Triggers = append(workflow.Triggers, trigger)
+			}
+
+			util.LogStart(fmt.Sprintf("creating workflow %s", name))
+
+			if err := project.AddWorkflow(bctx.Cwd, namespace, workflow); err != nil {
+				return errors.Wrap(err, "🚫 failed to AddWorkflow")
+			}
+
+			util.LogDone(fmt.Sprintf("workflow %s created", name))
+
+			return nil
+		},
+	}
+
+	cwd, err := os.Getwd()
+	if err != nil {
+		cwd = "$HOME"
+	}
+
+	cmd.Flags().String(dirFlag, cwd, "the directory of the project to add the workflow to")
+	cmd.Flags().String(namespaceFlag, "", "the namespace to add the workflow to (the default namespace if not set)")
+	cmd.Flags().StringArray(stepFlag, []string{}, "a module to run, or a comma-separated group of modules to run in parallel (can be repeated)")
+	cmd.Flags().StringArray(triggerFlag, []string{}, "a trigger for the workflow as source:topic, optionally followed by :sink:sinkTopic (can be repeated)")
+	cmd.Flags().String(responseFlag, "", "the module (or state key) whose result the workflow responds with")
+
+	return cmd
+}
+
+// parseWorkflowStep parses a step of a workflow in namespace given as a module name, or a comma-separated group of
+// module names. Module names can be qualified with their namespace (namespace/name), see project.Context.ModuleFQMN.
+func parseWorkflowStep(bctx *project.Context, namespace, spec string) (executable.Executable, error) {
+	names := strings.Split(spec, ",")
+	mods := make([]executable.ExecutableMod, len(names))
+
+	for i, name := range names {
+		name = strings.TrimSpace(name)
+
+		FQMN, err := bctx.ModuleFQMN(namespace, name)
+		if err != nil {
+			return executable.Executable{}, errors.Wrap(err, "failed to ModuleFQMN")
+		}
+
+		mods[i] = executable.ExecutableMod{FQMN: FQMN}
+	}
+
+	if len(mods) == 1 {
+		return executable.Executable{ExecutableMod: mods[0]}, nil
+	}
+
+	return executable.Executable{Group: mods}, nil
+}
+
+// setWorkflowResponseKey sets the state key of the last step module with the given name to that name.
+func setWorkflowResponseKey(steps []executable.Executable, name string) {
+	for i := len(steps) - 1; i >= 0; i-- {
+		mods := []*executable.ExecutableMod{}
+		if steps[i].IsGroup() {
+			for j := range steps[i].Group {
+				mods = append(mods, &steps[i].Group[j])
+			}
+		} else {
+			mods = append(mods, &steps[i].ExecutableMod)
+		}
+
+		for _, mod := range mods {
+			if FQMN, err := fqmn.Parse(mod.FQMN); err == nil && FQMN.Name == name {
+				mod.As = name
+				return
+			}
+		}
+	}
+}
+
+// parseWorkflowTrigger parses a trigger given as source:topic or source:topic:sink:sinkTopic.
+func parseWorkflowTrigger(spec string) (tenant.Trigger, error) {
+	parts := strings.Split(spec, ":")
+	if (len(parts) != 2 && len(parts) != 4) || parts[0] == "" || parts[1] == "" {
+		return tenant.Trigger{}, fmt.Errorf("trigger %q must be source:topic or source:topic:sink:sinkTopic", spec)
+	}
+
+	trigger := tenant.Trigger{
+		Source: parts[0],
+		Topic:  parts[1],
+	}
+
+	if len(parts) == 4 {
+		trigger.Sink = parts[2]
+		trigger.SinkTopic = parts[3]
+	}
+
+	return trigger, nil
+}
@@ -0,0 +1,185 @@
+package command
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/suborbital/subo/project"
+
+	"github.com/suborbital/systemspec/tenant"
+	"github.com/suborbital/systemspec/tenant/executable"
+)
+
+func TestSetWorkflowResponseKey(t *testing.T) {
+	steps := []executable.Executable{
+		{ExecutableMod: executable.ExecutableMod{FQMN: "fqmn://com.suborbital.app/default/render"}},
+		{Group: []executable.ExecutableMod{
+			{FQMN: "fqmn://com.suborbital.app/default/get-avatar"},
+			{FQMN: "fqmn://com.suborbital.app/default/render"},
+		}},
+	}
+
+	setWorkflowResponseKey(steps, "render")
+	assert.Empty(t, steps[0].As)
+	assert.Empty(t, steps[1].Group[0].As)
+	assert.Equal(t, "render", steps[1].Group[1].As)
+}
+
+func TestParseWorkflowTrigger(t *testing.T) {
+	trigger, err := parseWorkflowTrigger("nats:user-created")
+	require.NoError(t, err)
+	assert.Equal(t, tenant.Trigger{Source: "nats", Topic: "user-created"}, trigger)
+
+	trigger, err = parseWorkflowTrigger("kafka:orders:nats:order-totals")
+	require.NoError(t, err)
+	assert.Equal(t, tenant.Trigger{Source: "kafka", Topic: "orders", Sink: "nats", SinkTopic: "order-totals"}, trigger)
+
+	for _, spec := range []string{"orders", ":orders", "kafka:orders:nats"} {
+		_, err := parseWorkflowTrigger(spec)
+		assert.Error(t, err, spec)
+	}
+}
+
+const testWorkflowTenantJSON = `{
+	"identifier": "com.suborbital.app",
+	"specVersion": 1,
+	"tenantVersion": 1,
+	"defaultNamespace": {"name": "default"},
+	"namespaces": [
+		{
+			"name": "users",
+			"connections": [
+				{"type": "redis", "name": "sessions", "config": {"serverAddress": "${SESSIONS_HOST}", "password": "secret://sessions-password"}}
+			]
+		},
+		{"name": "billing"}
+	]
+}`
+
+const testWorkflowConnections = `defaultNamespace:
+  connections:
+    - type: redis
+      name: cache
+      config:
+        serverAddress: redis://localhost:6379
+        password: secret://cache-password
+`
+
+// runCreateWorkflow runs subo create workflow in dir with the given arguments.
+func runCreateWorkflow(dir string, args ...string) error {
+	cmd := CreateWorkflowCmd()
+	cmd.SetArgs(append(args, "--dir", dir))
+	cmd.SetOut(io.Discard)
+	cmd.SetErr(io.Discard)
+
+	return cmd.Execute()
+}
+
+func TestCreateWorkflowCmd(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+
+	t.Setenv("SESSIONS_HOST", "redis://sessions:6379")
+	t.Setenv("SUBO_SECRET_SESSIONS_PASSWORD", "sessions-hunter2")
+	t.Setenv("SUBO_SECRET_CACHE_PASSWORD", "cache-hunter2")
+
+	for _, path := range []string{"hello", "goodbye", "users/hello"} {
+		yaml := "name: " + filepath.Base(path) + "\nlang: wat\n"
+		if filepath.Dir(path) != "." {
+			yaml += "namespace: " + filepath.Dir(path) + "\n"
+		}
+
+		require.NoError(t, os.MkdirAll(filepath.Join(dir, path), 0755))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, path, ".module.yaml"), []byte(yaml), 0644))
+	}
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "tenant.json"), []byte(testWorkflowTenantJSON), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "Connections.yaml"), []byte(testWorkflowConnections), 0644))
+
+	require.NoError(t, runCreateWorkflow(dir, "greet", "--step", "hello", "--step", "goodbye,hello", "--response", "hello"))
+	require.NoError(t, runCreateWorkflow(dir, "greet", "--namespace", "users", "--step", "hello", "--step", "goodbye"))
+	require.NoError(t, runCreateWorkflow(dir, "greet", "--namespace", "billing", "--step", "users/hello"))
+
+	t.Run("adds the workflow to tenant.json", func(t *testing.T) {
+		ctx, err := project.ForDirectory(dir)
+		require.NoError(t, err)
+
+		workflows := ctx.TenantConfig.DefaultNamespace.Workflows
+		require.Len(t, workflows, 1)
+		assert.Equal(t, "greet", workflows[0].Name)
+		assert.Equal(t, "hello", workflows[0].Response)
+
+		if assert.Len(t, workflows[0].Steps, 2) {
+			assert.Equal(t, "fqmn://com.suborbital.app/default/hello", workflows[0].Steps[0].FQMN)
+			assert.Len(t, workflows[0].Steps[1].Group, 2)
+			assert.Equal(t, "hello", workflows[0].Steps[1].Group[1].As)
+		}
+
+		// Each step uses the module in the workflow's namespace, or the only module with that name.
+		usersWorkflows := ctx.TenantConfig.Namespaces[0].Workflows
+		if assert.Len(t, usersWorkflows, 1) && assert.Len(t, usersWorkflows[0].Steps, 2) {
+			assert.Equal(t, "fqmn://com.suborbital.app/users/hello", usersWorkflows[0].Steps[0].FQMN)
+			assert.Equal(t, "fqmn://com.suborbital.app/default/goodbye", usersWorkflows[0].Steps[1].FQMN)
+		}
+
+		billingWorkflows := ctx.TenantConfig.Namespaces[1].Workflows
+		if assert.Len(t, billingWorkflows, 1) {
+			assert.Equal(t, "fqmn://com.suborbital.app/users/hello", billingWorkflows[0].Steps[0].FQMN)
+		}
+	})
+
+	t.Run("keeps connection values unresolved", func(t *testing.T) {
+		tenantJSON, err := os.ReadFile(filepath.Join(dir, "tenant.json"))
+		require.NoError(t, err)
+
+		assert.Contains(t, string(tenantJSON), "${SESSIONS_HOST}")
+		assert.Contains(t, string(tenantJSON), "secret://sessions-password")
+		assert.NotContains(t, string(tenantJSON), "redis://sessions:6379")
+		assert.NotContains(t, string(tenantJSON), "hunter2")
+
+		// Connections.yaml isn't merged into tenant.json.
+		assert.NotContains(t, string(tenantJSON), "cache")
+	})
+
+	t.Run("rejects unknown modules", func(t *testing.T) {
+		err := runCreateWorkflow(dir, "farewell", "--step", "hello,nope")
+		if assert.Error(t, err) {
+			assert.Contains(t, err.Error(), "module nope does not exist")
+		}
+	})
+
+	t.Run("rejects ambiguous modules", func(t *testing.T) {
+		err := runCreateWorkflow(dir, "farewell", "--namespace", "billing", "--step", "hello")
+		if assert.Error(t, err) {
+			assert.Contains(t, err.Error(), "module hello exists in namespaces default, users")
+		}
+	})
+
+	t.Run("rejects unknown namespaces", func(t *testing.T) {
+		err := runCreateWorkflow(dir, "farewell", "--namespace", "shipping", "--step", "goodbye")
+		if assert.Error(t, err) {
+			assert.Contains(t, err.Error(), "namespace shipping does not exist in tenant.json")
+		}
+	})
+
+	t.Run("rejects duplicate workflow names", func(t *testing.T) {
+		err := runCreateWorkflow(dir, "greet", "--step", "goodbye")
+		if assert.Error(t, err) {
+			assert.Contains(t, err.Error(), "workflow greet already exists in namespace default")
+		}
+	})
+
+	t.Run("leaves tenant.json alone when rejected", func(t *testing.T) {
+		ctx, err := project.ForDirectory(dir)
+		require.NoError(t, err)
+
+		assert.Len(t, ctx.TenantConfig.DefaultNamespace.Workflows, 1)
+		assert.Len(t, ctx.TenantConfig.Namespaces[0].Workflows, 1)
+		assert.Len(t, ctx.TenantConfig.Namespaces[1].Workflows, 1)
+	})
+}
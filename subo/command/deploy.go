@@ -40,6 +40,10 @@ func DeployCmd() *cobra.Command {
 				return errors.Wrap(err, "failed to project.ForDirectory")
 			}
 
+			if err := applySettings(cmd, ctx.Defaults, "deploy"); err != nil {
+				return errors.Wrap(err, "failed to applySettings")
+			}
+
 			dplyr := deployer.New(&util.PrintLogger{})
 			var deployJob deployer.DeployJob
 
@@ -43,9 +43,14 @@ func DevCmd() *cobra.Command {
 				util.LogInfo("Running E2Core with debug logging")
 			}
 
-			dockerCmd := fmt.Sprintf("docker run -v=%s:/home/e2core -e=E2CORE_HTTP_PORT=%s %s -p=%s:%s suborbital/e2core:%s e2core start", bctx.Cwd, port, envvar, port, port, release.RuntimeVersion)
+			engine, err := util.Engine()
+			if err != nil {
+				return errors.Wrap(err, "🚫 failed to util.Engine")
+			}
+
+			runCmd := engine.Command(fmt.Sprintf("run -v=%s:/home/e2core -e=E2CORE_HTTP_PORT=%s %s -p=%s:%s suborbital/e2core:%s e2core start", bctx.Cwd, port, envvar, port, port, release.RuntimeVersion))
 
-			_, err = util.Command.Run(dockerCmd)
+			_, err = util.Command.Run(runCmd)
 			if err != nil {
 				return errors.Wrap(err, "🚫 failed to run dev server")
 			}
@@ -15,6 +15,7 @@ const (
 	localFlag           = "local"
 	proxyPortFlag       = "proxy-port"
 	domainFlag          = "domain"
-	// methodFlag          = "method"
-	// typeFlag            = "type".
+	stepFlag            = "step"
+	triggerFlag         = "trigger"
+	responseFlag        = "response"
 )
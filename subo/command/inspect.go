@@ -0,0 +1,71 @@
+package command
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+
+	"github.com/suborbital/subo/builder"
+)
+
+// InspectCmd returns the inspect command.
+func InspectCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "inspect <file.wasm>",
+		Short: "print the build metadata of a Wasm module",
+		Long:  `print the build metadata (git commit, builder tag, language etc) embedded into a Wasm module by subo build --metadata`,
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			wasmBytes, err := ioutil.ReadFile(args[0])
+			if err != nil {
+				return errors.Wrap(err, "🚫 failed to ReadFile")
+			}
+
+			meta, err := builder.ReadMetadata(wasmBytes)
+			if err != nil {
+				if errors.Is(err, os.ErrNotExist) {
+					return fmt.Errorf("🚫 %s does not contain build metadata", args[0])
+				}
+
+				return errors.Wrap(err, "🚫 failed to ReadMetadata")
+			}
+
+			if asJSON, _ := cmd.Flags().GetBool("json"); asJSON {
+				metaJSON, err := json.MarshalIndent(meta, "", "  ")
+				if err != nil {
+					return errors.Wrap(err, "failed to MarshalIndent")
+				}
+
+				fmt.Println(string(metaJSON))
+
+				return nil
+			}
+
+			gitSHA := meta.GitSHA
+			if gitSHA == "" {
+				gitSHA = "(unknown)"
+			} else if meta.GitDirty {
+				gitSHA += " (dirty)"
+			}
+
+			fmt.Printf("module:       %s\n", meta.Module)
+			fmt.Printf("namespace:    %s\n", meta.Namespace)
+			fmt.Printf("lang:         %s\n", meta.Lang)
+			fmt.Printf("api version:  %s\n", meta.APIVersion)
+			fmt.Printf("toolchain:    %s\n", meta.Toolchain)
+			fmt.Printf("builder tag:  %s\n", meta.BuilderTag)
+			fmt.Printf("subo version: %s\n", meta.SuboVersion)
+			fmt.Printf("git commit:   %s\n", gitSHA)
+
+			return nil
+		},
+	}
+
+	cmd.Flags().Bool("json", false, "print the metadata as JSON")
+
+	return cmd
+}
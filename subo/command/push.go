@@ -20,16 +20,11 @@ var validPublishTypes = map[string]bool{
 // PushCmd packages the current project into a Bindle and pushes it to a Bindle server.
 func PushCmd() *cobra.Command {
 	cmd := &cobra.Command{
-		Use:   "push",
+		Use:   "push [type]",
 		Short: "publish a project",
 		Long:  "publish the current project to a remote server (Docker, Bindle, etc.)",
-		Args:  cobra.ExactArgs(1),
+		Args:  cobra.MaximumNArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
-			publishType := args[0]
-			if _, valid := validPublishTypes[publishType]; !valid {
-				return fmt.Errorf("invalid publish type %s", publishType)
-			}
-
 			cwd, err := os.Getwd()
 			if err != nil {
 				return errors.Wrap(err, "failed to Getwd")
@@ -40,6 +35,29 @@ func PushCmd() *cobra.Command {
 				return errors.Wrap(err, "failed to project.ForDirectory")
 			}
 
+			if err := applySettings(cmd, ctx.Defaults, "push"); err != nil {
+				return errors.Wrap(err, "failed to applySettings")
+			}
+
+			publishType := ""
+			if len(args) > 0 {
+				publishType = args[0]
+			} else {
+				// The publish type can default to the push type from the environment, subo.yaml or the user's config.
+				publishType, _ = ctx.Defaults.Value("push", "type")
+			}
+
+			if _, valid := validPublishTypes[publishType]; !valid {
+				return fmt.Errorf("invalid publish type %q", publishType)
+			}
+
+			// The pushed tenant config is the same one that subo build packages.
+			if env, _ := cmd.Flags().GetString("env"); env != "" && ctx.TenantConfig != nil {
+				if err := ctx.ApplyEnvironment(env); err != nil {
+					return errors.Wrap(err, "🚫 failed to ApplyEnvironment")
+				}
+			}
+
 			pshr := publisher.New(&util.PrintLogger{})
 			var pubJob publisher.PublishJob
 
@@ -60,5 +78,7 @@ func PushCmd() *cobra.Command {
 		},
 	}
 
+	cmd.Flags().String("env", "", "push the project with the tenant config overlay for the named environment (tenant.{env}.yaml) merged in")
+
 	return cmd
 }
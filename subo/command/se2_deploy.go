@@ -1,6 +1,7 @@
 package command
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"os"
@@ -144,17 +145,22 @@ func SE2DeployCommand() *cobra.Command {
 			util.LogStart("installing...")
 
 			if localInstall {
+				engine, err := util.Engine()
+				if err != nil {
+					return errors.Wrap(err, "🚫 failed to util.Engine")
+				}
+
 				var compose string
-				if _, err := util.Command.Run("docker compose version 2>&1 >/dev/null"); err == nil {
+				if _, err := util.Command.Run(engine.Command("compose version 2>&1 >/dev/null")); err == nil {
 					// Use Compose v2 if we're positive we have it
-					compose = "docker compose"
-				} else if _, err := exec.LookPath("docker-compose"); err == nil {
-					// Fall back to legacy compose if available.
-					compose = "docker-compose"
+					compose = engine.Command("compose")
+				} else if _, err := exec.LookPath(engine.Name + "-compose"); err == nil {
+					// Fall back to legacy compose (docker-compose, podman-compose) if available.
+					compose = engine.Name + "-compose"
 				} else {
 					// YOLO. Try Compose V2 anyway. Works with containerd/nerdctl.
 					// See: https://github.com/containerd/nerdctl/issues/1368
-					compose = "docker compose"
+					compose = engine.Command("compose")
 				}
 
 				command := fmt.Sprintf("%s up -d", compose)
@@ -164,7 +170,7 @@ func SE2DeployCommand() *cobra.Command {
 					return errors.Wrapf(err, "🚫 failed to run `%s`", command)
 				}
 
-				util.LogInfo(fmt.Sprintf("use `docker ps` and `%s logs` to check deployment status", compose))
+				util.LogInfo(fmt.Sprintf("use `%s` and `%s logs` to check deployment status", engine.Command("ps"), compose))
 
 				proxyPortStr := strconv.Itoa(proxyPort)
 				proxy := localproxy.New("editor.suborbital.network", proxyPortStr)
@@ -320,15 +326,15 @@ func getStorageClass() (string, error) {
 }
 
 func detectStorageClass() (string, error) {
-	output, err := util.Command.Run("kubectl get storageclass --output=name")
+	result, err := util.Command.Exec(context.Background(), util.ArgsCmd("kubectl", "get", "storageclass", "--output=name"))
 	if err != nil {
 		return "", errors.Wrap(err, "failed to get default storageclass")
 	}
 
-	// output will look like: storageclass.storage.k8s.io/do-block-storage
-	// so split on the / and return the last part.
+	// stdout will look like: storageclass.storage.k8s.io/do-block-storage
+	// so split on the / and return the last part. Warnings etc on stderr are ignored.
 
-	outputParts := strings.Split(output, "/")
+	outputParts := strings.Split(strings.TrimSpace(result.Stdout), "/")
 	if len(outputParts) != 2 {
 		return "", errors.New("could not automatically determine storage class")
 	}
@@ -344,7 +350,7 @@ func createConfigMap(cwd string) error {
 		return errors.Wrap(err, "failed to Stat se2-config.yaml")
 	}
 
-	if _, err := util.Command.Run(fmt.Sprintf("kubectl create configmap se2-config --from-file=se2-config.yaml=%s -n suborbital", configFilepath)); err != nil {
+	if _, err := util.Command.Exec(context.Background(), util.ArgsCmd("kubectl", "create", "configmap", "se2-config", "--from-file=se2-config.yaml="+configFilepath, "-n", "suborbital")); err != nil {
 		return errors.Wrap(err, "failed to create configmap (you may need to run `kubectl delete configmap se2-config -n suborbital`)")
 	}
 
@@ -0,0 +1,135 @@
+package command
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+
+	"github.com/suborbital/subo/project"
+	"github.com/suborbital/subo/subo/input"
+	"github.com/suborbital/subo/subo/util"
+)
+
+// SecretSetCmd returns the secret set command.
+func SecretSetCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "set <name> [value]",
+		Short: "add a secret to the project's Secrets.yaml",
+		Long:  `encrypt a secret and add it to the project's Secrets.yaml, where connections can refer to it as secret://<name>. The value is read from stdin if it isn't given`,
+		Args:  cobra.RangeArgs(1, 2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cwd, err := os.Getwd()
+			if err != nil {
+				return errors.Wrap(err, "failed to Getwd")
+			}
+
+			name := args[0]
+
+			var value string
+			if len(args) == 2 {
+				value = args[1]
+			} else {
+				fmt.Printf("value for secret %s: ", name)
+
+				value, err = input.ReadStdinString()
+				if err != nil {
+					return errors.Wrap(err, "failed to ReadStdinString")
+				}
+			}
+
+			keyPath, created, err := project.EnsureSecretsKey()
+			if err != nil {
+				return errors.Wrap(err, "🚫 failed to EnsureSecretsKey")
+			}
+
+			if created {
+				util.LogInfo(fmt.Sprintf("created secrets key %s, keep it safe and don't commit it", keyPath))
+			}
+
+			secrets, err := project.OpenSecretsFile(cwd)
+			if err != nil {
+				return errors.Wrap(err, "🚫 failed to OpenSecretsFile")
+			}
+
+			if err := secrets.Set(name, value); err != nil {
+				return errors.Wrap(err, "🚫 failed to Set")
+			}
+
+			if err := secrets.Write(); err != nil {
+				return errors.Wrap(err, "🚫 failed to Write")
+			}
+
+			util.LogDone(fmt.Sprintf("secret %s set in %s", name, project.SecretsFileName))
+
+			return nil
+		},
+	}
+
+	return cmd
+}
+
+// SecretListCmd returns the secret list command.
+func SecretListCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "list the secrets in the project's Secrets.yaml",
+		Long:  `list the names of the secrets in the project's Secrets.yaml (values are not printed)`,
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cwd, err := os.Getwd()
+			if err != nil {
+				return errors.Wrap(err, "failed to Getwd")
+			}
+
+			secrets, err := project.OpenSecretsFile(cwd)
+			if err != nil {
+				return errors.Wrap(err, "🚫 failed to OpenSecretsFile")
+			}
+
+			for _, name := range secrets.Names() {
+				fmt.Println(name)
+			}
+
+			return nil
+		},
+	}
+
+	return cmd
+}
+
+// SecretRemoveCmd returns the secret remove command.
+func SecretRemoveCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "remove <name>",
+		Short: "remove a secret from the project's Secrets.yaml",
+		Long:  `remove a secret from the project's Secrets.yaml`,
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cwd, err := os.Getwd()
+			if err != nil {
+				return errors.Wrap(err, "failed to Getwd")
+			}
+
+			secrets, err := project.OpenSecretsFile(cwd)
+			if err != nil {
+				return errors.Wrap(err, "🚫 failed to OpenSecretsFile")
+			}
+
+			if !secrets.Remove(args[0]) {
+				return fmt.Errorf("🚫 secret %s is not in %s", args[0], project.SecretsFileName)
+			}
+
+			if err := secrets.Write(); err != nil {
+				return errors.Wrap(err, "🚫 failed to Write")
+			}
+
+			util.LogDone(fmt.Sprintf("secret %s removed from %s", args[0], project.SecretsFileName))
+
+			return nil
+		},
+	}
+
+	return cmd
+}
@@ -0,0 +1,88 @@
+package command
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+
+	"github.com/suborbital/subo/project"
+)
+
+// packageSettings are the build command's flags that belong in the package section of subo.yaml.
+var packageSettings = map[string]bool{
+	"docker":    true,
+	"no-bundle": true,
+	"make":      true,
+	"env":       true,
+}
+
+// argSettings are the settings in each section of subo.yaml that set a command's argument rather than a flag.
+var argSettings = map[string]map[string]bool{
+	"push": {"type": true},
+}
+
+// applySettings sets each of the command's flags that wasn't passed on the command line from the first of
+// the environment (e.g. SUBO_BUILD_BUILDER_TAG), the project's subo.yaml and then the user's config file that
+// has a value for it in the command's section. Flags with no value anywhere keep their built-in defaults.
+// Every command's --env flag is set from the package section, so that they use the same environment as subo build.
+func applySettings(cmd *cobra.Command, defaults *project.Defaults, section string) error {
+	if err := validateSettings(cmd, defaults, section); err != nil {
+		return errors.Wrap(err, "failed to validateSettings")
+	}
+
+	var applyErr error
+
+	// Inherited flags (such as --engine) are used before the command runs, so they can't be set here.
+	cmd.LocalFlags().VisitAll(func(f *pflag.Flag) {
+		if applyErr != nil || f.Changed || f.Name == "help" {
+			return
+		}
+
+		flagSection := settingSection(section, f.Name)
+
+		val, exists := defaults.Value(flagSection, f.Name)
+		if !exists || val == f.DefValue {
+			// Setting a flag marks it as changed, so flags are only set to values other than their defaults.
+			return
+		}
+
+		if err := cmd.Flags().Set(f.Name, val); err != nil {
+			applyErr = errors.Wrapf(err, "invalid value %q for %s setting %s", val, flagSection, f.Name)
+		}
+	})
+
+	return applyErr
+}
+
+// validateSettings checks that every setting for the command in the project file and user config is one of its flags.
+func validateSettings(cmd *cobra.Command, defaults *project.Defaults, section string) error {
+	sections := []string{section}
+	if section == "build" {
+		sections = append(sections, "package")
+	}
+
+	for _, sec := range sections {
+		for _, name := range defaults.Names(sec) {
+			if sec == section && argSettings[section][name] {
+				continue
+			}
+
+			if cmd.LocalFlags().Lookup(name) == nil || settingSection(section, name) != sec {
+				return fmt.Errorf("%s is not a valid %s setting", name, sec)
+			}
+		}
+	}
+
+	return nil
+}
+
+// settingSection returns the section of subo.yaml that a command's flag is set in.
+func settingSection(section, name string) string {
+	if name == "env" || (section == "build" && packageSettings[name]) {
+		return "package"
+	}
+
+	return section
+}
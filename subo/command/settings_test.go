@@ -0,0 +1,144 @@
+package command
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/suborbital/subo/project"
+)
+
+func testSettingsCmd(t *testing.T, args ...string) *cobra.Command {
+	cmd := &cobra.Command{Use: "build"}
+	cmd.Flags().String("builder-tag", "", "")
+	cmd.Flags().String("mountpath", "", "")
+	cmd.Flags().String("js-toolchain", "npm", "")
+	cmd.Flags().StringSlice("langs", []string{}, "")
+	cmd.Flags().Bool("native", false, "")
+	cmd.Flags().Bool("docker", false, "")
+	cmd.Flags().Int("jobs", 1, "")
+
+	require.NoError(t, cmd.ParseFlags(args))
+
+	return cmd
+}
+
+func TestApplySettings(t *testing.T) {
+	projectDir := t.TempDir()
+	configDir := t.TempDir()
+
+	t.Setenv("XDG_CONFIG_HOME", configDir)
+	t.Setenv("HOME", configDir)
+
+	projectYaml := `build:
+  builderTag: v0.6.0
+  mountpath: /project
+  langs: [rust, tinygo]
+  native: true
+package:
+  docker: true
+`
+
+	userYaml := `build:
+  builder-tag: v0.5.0
+  jsToolchain: yarn
+  jobs: 4
+`
+
+	require.NoError(t, os.WriteFile(filepath.Join(projectDir, "subo.yaml"), []byte(projectYaml), 0644))
+	require.NoError(t, os.MkdirAll(filepath.Join(configDir, "subo"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(configDir, "subo", "config.yaml"), []byte(userYaml), 0644))
+
+	t.Setenv("SUBO_BUILD_MOUNTPATH", "/from-env")
+
+	// Settings come from the project root's subo.yaml even when run from within the project.
+	moduleDir := filepath.Join(projectDir, "hello")
+	require.NoError(t, os.MkdirAll(moduleDir, 0755))
+
+	defaults, err := project.LoadDefaults(moduleDir)
+	require.NoError(t, err)
+
+	cmd := testSettingsCmd(t, "--jobs", "2")
+	require.NoError(t, applySettings(cmd, defaults, "build"))
+
+	get := func(name string) string {
+		return cmd.Flags().Lookup(name).Value.String()
+	}
+
+	assert.Equal(t, "2", get("jobs"), "flags take precedence over everything")
+	assert.Equal(t, "/from-env", get("mountpath"), "the environment takes precedence over subo.yaml")
+	assert.Equal(t, "v0.6.0", get("builder-tag"), "subo.yaml takes precedence over the user config")
+	assert.Equal(t, "yarn", get("js-toolchain"), "the user config takes precedence over defaults")
+	assert.Equal(t, "[rust,tinygo]", get("langs"))
+	assert.Equal(t, "true", get("native"))
+	assert.Equal(t, "true", get("docker"))
+}
+
+func TestApplySettings_DefaultValue(t *testing.T) {
+	projectDir := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+
+	require.NoError(t, os.WriteFile(filepath.Join(projectDir, "subo.yaml"), []byte("build:\n  native: false\n"), 0644))
+
+	defaults, err := project.LoadDefaults(projectDir)
+	require.NoError(t, err)
+
+	cmd := testSettingsCmd(t)
+	require.NoError(t, applySettings(cmd, defaults, "build"))
+
+	// Flags such as deploy's --update-templates are checked with Changed, so settings matching the default leave them unset.
+	assert.False(t, cmd.Flags().Changed("native"))
+}
+
+func TestApplySettings_Invalid(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+
+	tests := map[string]string{
+		"unknown setting":             "build:\n  unknown: true\n",
+		"package setting under build": "build:\n  docker: true\n",
+		"invalid value":               "build:\n  jobs: lots\n",
+		"env under build":             "build:\n  env: staging\n",
+	}
+
+	for name, projectYaml := range tests {
+		t.Run(name, func(t *testing.T) {
+			projectDir := t.TempDir()
+			require.NoError(t, os.WriteFile(filepath.Join(projectDir, "subo.yaml"), []byte(projectYaml), 0644))
+
+			defaults, err := project.LoadDefaults(projectDir)
+			require.NoError(t, err)
+
+			assert.Error(t, applySettings(testSettingsCmd(t), defaults, "build"))
+		})
+	}
+}
+
+func TestApplySettings_Push(t *testing.T) {
+	projectDir := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+
+	projectYaml := `package:
+  env: staging
+push:
+  type: docker
+`
+
+	require.NoError(t, os.WriteFile(filepath.Join(projectDir, "subo.yaml"), []byte(projectYaml), 0644))
+
+	defaults, err := project.LoadDefaults(projectDir)
+	require.NoError(t, err)
+
+	cmd := &cobra.Command{Use: "push"}
+	cmd.Flags().String("env", "", "")
+
+	// The push type is the command's argument rather than a flag, and --env comes from the package section.
+	require.NoError(t, applySettings(cmd, defaults, "push"))
+	assert.Equal(t, "staging", cmd.Flags().Lookup("env").Value.String())
+
+	pushType, _ := defaults.Value("push", "type")
+	assert.Equal(t, "docker", pushType)
+}
@@ -0,0 +1,88 @@
+package command
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+
+	"github.com/suborbital/subo/project"
+)
+
+// ValidateCmd returns the validate command.
+func ValidateCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "validate [dir]",
+		Short: "check a project for configuration mistakes",
+		Long: `check a project's modules, tenant.json, Queries.yaml, Connections.yaml and environment overlay for mistakes
+(such as workflows referencing missing modules, malformed FQMNs, invalid langs and misconfigured connections) without building it`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			dir := "."
+			if len(args) > 0 {
+				dir = args[0]
+			}
+
+			// Without --env, the same config that subo build packages is validated. Settings files
+			// that can't be loaded are reported as problems by Validate.
+			if defaults, err := project.LoadDefaults(dir); err == nil {
+				if err := applySettings(cmd, defaults, "validate"); err != nil {
+					return errors.Wrap(err, "🚫 failed to applySettings")
+				}
+			}
+
+			env, _ := cmd.Flags().GetString("env")
+
+			format, _ := cmd.Flags().GetString("output")
+			if format != "text" && format != "sarif" {
+				return fmt.Errorf("🚫 invalid output format %q (must be text or sarif)", format)
+			}
+
+			problems, err := project.Validate(dir, env)
+			if err != nil {
+				return errors.Wrap(err, "🚫 failed to Validate")
+			}
+
+			out := io.Writer(os.Stdout)
+
+			if outputFile, _ := cmd.Flags().GetString("output-file"); outputFile != "" {
+				file, err := os.Create(outputFile)
+				if err != nil {
+					return errors.Wrap(err, "failed to Create output file")
+				}
+
+				defer file.Close()
+
+				out = file
+			}
+
+			if format == "sarif" {
+				if err := project.WriteSARIF(out, problems); err != nil {
+					return errors.Wrap(err, "failed to WriteSARIF")
+				}
+			} else {
+				for _, p := range problems {
+					fmt.Fprintln(out, p.String())
+				}
+			}
+
+			if len(problems) > 0 {
+				return fmt.Errorf("🚫 found %d problems", len(problems))
+			}
+
+			if format == "text" {
+				fmt.Fprintln(out, "✅ no problems found")
+			}
+
+			return nil
+		},
+	}
+
+	cmd.Flags().String("env", "", "validate the project with the tenant config overlay for the named environment (tenant.{env}.yaml) merged in")
+	cmd.Flags().String("output", "text", "the format to report problems in (text or sarif)")
+	cmd.Flags().String("output-file", "", "if passed, problems are written to the provided file rather than stdout")
+
+	return cmd
+}
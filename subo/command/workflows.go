@@ -0,0 +1,83 @@
+package command
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+
+	"github.com/suborbital/subo/project"
+	"github.com/suborbital/subo/subo/util"
+)
+
+// WorkflowsGraphCmd returns the workflows graph command.
+func WorkflowsGraphCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "graph [workflow...]",
+		Short: "render the project's workflows as a graph",
+		Long: `render the steps of the project's workflows (or only those named) as a Graphviz DOT or Mermaid graph, with groups
+fanning out from the steps around them. Modules are annotated with their lang and namespace, and modules that aren't in the project are highlighted`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cwd, err := os.Getwd()
+			if err != nil {
+				return errors.Wrap(err, "failed to Getwd")
+			}
+
+			ctx, err := project.ForDirectory(cwd)
+			if err != nil {
+				return errors.Wrap(err, "failed to project.ForDirectory")
+			}
+
+			// Without --env, the same workflows that subo build packages are graphed.
+			if err := applySettings(cmd, ctx.Defaults, "workflows"); err != nil {
+				return errors.Wrap(err, "🚫 failed to applySettings")
+			}
+
+			format, _ := cmd.Flags().GetString("format")
+			namespace, _ := cmd.Flags().GetString(namespaceFlag)
+			env, _ := cmd.Flags().GetString("env")
+
+			if ctx.TenantConfig == nil {
+				return errors.New("🚫 no tenant.json found in current directory")
+			}
+
+			if env != "" {
+				if err := ctx.ApplyEnvironment(env); err != nil {
+					return errors.Wrap(err, "🚫 failed to ApplyEnvironment")
+				}
+			}
+
+			// The graph is rendered before the output file is created, so that nothing is left behind if it can't be.
+			graph := &bytes.Buffer{}
+			if err := ctx.WriteWorkflowGraph(graph, format, namespace, args); err != nil {
+				return errors.Wrap(err, "🚫 failed to WriteWorkflowGraph")
+			}
+
+			if outputFile, _ := cmd.Flags().GetString("output-file"); outputFile != "" {
+				if err := ioutil.WriteFile(outputFile, graph.Bytes(), util.PermFile); err != nil {
+					return errors.Wrap(err, "failed to WriteFile")
+				}
+
+				return nil
+			}
+
+			if _, err := io.Copy(os.Stdout, graph); err != nil {
+				return errors.Wrap(err, "failed to write graph")
+			}
+
+			return nil
+		},
+	}
+
+	cmd.Flags().String("format", project.GraphFormatDOT, fmt.Sprintf("the format to render the graph in (%s)", strings.Join(project.GraphFormats, " or ")))
+	cmd.Flags().String(namespaceFlag, "", "only render the workflows in the given namespace")
+	cmd.Flags().String("env", "", "render the workflows with the tenant config overlay for the named environment (tenant.{env}.yaml) merged in")
+	cmd.Flags().String("output-file", "", "if passed, the graph is written to the provided file rather than stdout")
+
+	return cmd
+}
@@ -0,0 +1,76 @@
+package command
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testGraphTenantJSON = `{
+	"identifier": "com.suborbital.app",
+	"specVersion": 1,
+	"tenantVersion": 1,
+	"defaultNamespace": {
+		"name": "default",
+		"workflows": [{"name": "hello", "steps": [{"executableMod": {"fqmn": "fqmn://com.suborbital.app/default/hello"}}]}]
+	}
+}`
+
+const testGraphStagingOverlay = `defaultNamespace:
+  workflows:
+    - name: goodbye
+      steps:
+        - executableMod:
+            fqmn: fqmn://com.suborbital.app/default/goodbye
+`
+
+// runWorkflowsGraph runs subo workflows graph in dir with the given arguments.
+func runWorkflowsGraph(t *testing.T, dir string, args ...string) error {
+	t.Helper()
+
+	cwd, err := os.Getwd()
+	require.NoError(t, err)
+	require.NoError(t, os.Chdir(dir))
+
+	defer func() {
+		require.NoError(t, os.Chdir(cwd))
+	}()
+
+	cmd := WorkflowsGraphCmd()
+	cmd.SetArgs(args)
+	cmd.SetOut(io.Discard)
+	cmd.SetErr(io.Discard)
+
+	return cmd.Execute()
+}
+
+func TestWorkflowsGraphCmd(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "tenant.json"), []byte(testGraphTenantJSON), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "tenant.staging.yaml"), []byte(testGraphStagingOverlay), 0644))
+
+	outputFile := filepath.Join(t.TempDir(), "graph.dot")
+
+	t.Run("doesn't create the output file for invalid formats or workflows", func(t *testing.T) {
+		assert.Error(t, runWorkflowsGraph(t, dir, "--format", "svg", "--output-file", outputFile))
+		assert.Error(t, runWorkflowsGraph(t, dir, "nope", "--output-file", outputFile))
+		assert.NoFileExists(t, outputFile)
+	})
+
+	t.Run("uses the package environment from the settings", func(t *testing.T) {
+		t.Setenv("SUBO_PACKAGE_ENV", "staging")
+
+		require.NoError(t, runWorkflowsGraph(t, dir, "--output-file", outputFile))
+
+		graph, err := os.ReadFile(outputFile)
+		require.NoError(t, err)
+		assert.Contains(t, string(graph), "hello")
+		assert.Contains(t, string(graph), "goodbye")
+	})
+}
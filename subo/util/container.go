@@ -0,0 +1,156 @@
+package util
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// ContainerEngineEnvKey is the environment variable that can be used to choose a container engine.
+const ContainerEngineEnvKey = "SUBO_CONTAINER_ENGINE"
+
+// supportedContainerEngines are the container engines with docker-compatible CLIs, in order of preference.
+var supportedContainerEngines = []string{"docker", "podman", "nerdctl"}
+
+// ContainerEngine is a container engine CLI (docker, podman or nerdctl).
+type ContainerEngine struct {
+	Name string
+	// Rootless is true if the engine runs containers without root privileges, in which case
+	// files created in bind mounts are owned by the user running subo.
+	Rootless bool
+}
+
+var (
+	engineLock     sync.Mutex
+	engineOverride string
+	detectedEngine *ContainerEngine
+)
+
+// SetContainerEngine overrides the container engine used by subo. An empty name means auto-detect.
+func SetContainerEngine(name string) error {
+	if name != "" && !isSupportedEngine(name) {
+		return fmt.Errorf("%s is not a supported container engine (supported: %s)", name, strings.Join(supportedContainerEngines, ", "))
+	}
+
+	engineLock.Lock()
+	defer engineLock.Unlock()
+
+	engineOverride = name
+	detectedEngine = nil
+
+	return nil
+}
+
+// Engine returns the container engine to use, which is the override set by SetContainerEngine, then
+// the SUBO_CONTAINER_ENGINE environment variable, then the first supported engine found in PATH.
+func Engine() (*ContainerEngine, error) {
+	engineLock.Lock()
+	defer engineLock.Unlock()
+
+	if detectedEngine != nil {
+		return detectedEngine, nil
+	}
+
+	name := engineOverride
+	if name == "" {
+		name = os.Getenv(ContainerEngineEnvKey)
+	}
+
+	if name != "" {
+		if !isSupportedEngine(name) {
+			return nil, fmt.Errorf("%s is not a supported container engine (supported: %s)", name, strings.Join(supportedContainerEngines, ", "))
+		}
+
+		if _, err := exec.LookPath(name); err != nil {
+			return nil, errors.Wrapf(err, "container engine %s is not installed", name)
+		}
+	} else {
+		for _, e := range supportedContainerEngines {
+			if _, err := exec.LookPath(e); err == nil {
+				name = e
+				break
+			}
+		}
+
+		if name == "" {
+			return nil, fmt.Errorf("no container engine found, install one of: %s", strings.Join(supportedContainerEngines, ", "))
+		}
+	}
+
+	detectedEngine = &ContainerEngine{
+		Name:     name,
+		Rootless: isRootless(name),
+	}
+
+	return detectedEngine, nil
+}
+
+// Command returns the full command line to run the engine with the given arguments.
+func (c *ContainerEngine) Command(args string) string {
+	return fmt.Sprintf("%s %s", c.Name, args)
+}
+
+// Cmd returns a command that runs the engine with the given arguments, without a shell.
+func (c *ContainerEngine) Cmd(args ...string) Cmd {
+	return ArgsCmd(append([]string{c.Name}, args...)...)
+}
+
+// OwnsBindMounts returns true if files created by containers in bind mounts end up owned by root rather than the current user.
+func (c *ContainerEngine) OwnsBindMounts() bool {
+	// Docker Desktop and friends on macOS/Windows map ownership to the host user.
+	if runtime.GOOS != "linux" {
+		return false
+	}
+
+	return !c.Rootless && os.Getuid() != 0
+}
+
+// UserSpec returns the uid:gid of the current user, for restoring ownership of files created by containers.
+func (c *ContainerEngine) UserSpec() string {
+	return fmt.Sprintf("%d:%d", os.Getuid(), os.Getgid())
+}
+
+// MultiPlatformPushCommand returns the command to build the Dockerfile in the current
+// directory for amd64 and arm64, and push the resulting image (or manifest list).
+func (c *ContainerEngine) MultiPlatformPushCommand(image string) string {
+	platforms := "linux/amd64,linux/arm64"
+
+	switch c.Name {
+	case "podman":
+		return fmt.Sprintf("podman build . --platform %s --manifest %s && podman manifest push --all %s docker://%s", platforms, image, image, image)
+	case "nerdctl":
+		return fmt.Sprintf("nerdctl build . --platform %s -t %s && nerdctl push --all-platforms %s", platforms, image, image)
+	}
+
+	return fmt.Sprintf("docker buildx build . --platform %s -t %s --push", platforms, image)
+}
+
+func isSupportedEngine(name string) bool {
+	for _, e := range supportedContainerEngines {
+		if e == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// isRootless asks the engine whether it is running rootless.
+func isRootless(name string) bool {
+	info := NewCommandLineExecutor(SilentOutput, nil)
+
+	if name == "podman" {
+		out, err := info.Run("podman info --format '{{ .Host.Security.Rootless }}'")
+		return err == nil && strings.TrimSpace(out) == "true"
+	}
+
+	// docker and nerdctl both list rootless in their security options when running rootless.
+	out, err := info.Run(fmt.Sprintf("%s info --format '{{ json .SecurityOptions }}'", name))
+
+	return err == nil && strings.Contains(out, "rootless")
+}
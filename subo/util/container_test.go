@@ -0,0 +1,31 @@
+package util
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetContainerEngine(t *testing.T) {
+	assert.NoError(t, SetContainerEngine("podman"))
+	assert.NoError(t, SetContainerEngine(""))
+	assert.Error(t, SetContainerEngine("lxc"))
+}
+
+func TestContainerEngine_MultiPlatformPushCommand(t *testing.T) {
+	tests := []struct {
+		engine string
+		want   string
+	}{
+		{"docker", "docker buildx build . --platform linux/amd64,linux/arm64 -t org/app:1 --push"},
+		{"podman", "podman build . --platform linux/amd64,linux/arm64 --manifest org/app:1 && podman manifest push --all org/app:1 docker://org/app:1"},
+		{"nerdctl", "nerdctl build . --platform linux/amd64,linux/arm64 -t org/app:1 && nerdctl push --all-platforms org/app:1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.engine, func(t *testing.T) {
+			engine := &ContainerEngine{Name: tt.engine}
+			assert.Equal(t, tt.want, engine.MultiPlatformPushCommand("org/app:1"))
+		})
+	}
+}
@@ -2,9 +2,15 @@ package util
 
 import (
 	"bytes"
+	"context"
+	"fmt"
 	"io"
 	"os"
 	"os/exec"
+	"sort"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/pkg/errors"
 )
@@ -12,6 +18,58 @@ import (
 type CommandRunner interface {
 	Run(cmd string) (string, error)
 	RunInDir(cmd, dir string) (string, error)
+	// RunInDirContext runs a command in the specified directory, killing it and
+	// any processes it started if the context is cancelled before it finishes.
+	RunInDirContext(ctx context.Context, cmd, dir string) (string, error)
+	// Exec runs a command and returns its separate stdout and stderr, exit code and duration.
+	Exec(ctx context.Context, cmd Cmd) (*CmdResult, error)
+}
+
+// Cmd is a command to be run by a CommandRunner.
+type Cmd struct {
+	// Args is the program followed by its arguments, which are passed as-is without a shell
+	// so that arguments containing spaces or quotes don't need escaping.
+	Args []string
+	// Shell is a command line run with `sh -c`, for commands that need pipes, redirection etc. It is used if Args is empty.
+	Shell string
+	// Dir is the directory to run the command in, defaulting to the current directory.
+	Dir string
+	// Env is added to (and overrides) the environment that subo is running with.
+	Env map[string]string
+}
+
+// ArgsCmd returns a command that runs the given program and arguments without a shell.
+func ArgsCmd(args ...string) Cmd {
+	return Cmd{Args: args}
+}
+
+// ShellCmd returns a command that runs the given command line with `sh -c`.
+func ShellCmd(cmd string) Cmd {
+	return Cmd{Shell: cmd}
+}
+
+// String returns the command as it could be typed into a shell.
+func (c Cmd) String() string {
+	if len(c.Args) == 0 {
+		return c.Shell
+	}
+
+	quoted := make([]string, len(c.Args))
+	for i, a := range c.Args {
+		quoted[i] = quoteArg(a)
+	}
+
+	return strings.Join(quoted, " ")
+}
+
+// CmdResult is the result of running a command.
+type CmdResult struct {
+	Stdout string
+	Stderr string
+	// Output is stdout and stderr interleaved in the order they were written.
+	Output   string
+	ExitCode int
+	Duration time.Duration
 }
 
 type silentOutput bool
@@ -39,42 +97,162 @@ func NewCommandLineExecutor(silent silentOutput, writer io.Writer) *CommandLineE
 
 // Run runs a command, outputting to terminal and returning the full output and/or error.
 func (d *CommandLineExecutor) Run(cmd string) (string, error) {
-	return run(cmd, "", d.silent, d.writer)
+	return d.RunInDirContext(context.Background(), cmd, "")
 }
 
 // RunInDir runs a command in the specified directory and returns the full output or error.
 func (d *CommandLineExecutor) RunInDir(cmd, dir string) (string, error) {
-	return run(cmd, dir, d.silent, d.writer)
+	return d.RunInDirContext(context.Background(), cmd, dir)
 }
 
-func run(cmd, dir string, silent silentOutput, writer io.Writer) (string, error) {
+// RunInDirContext runs a command in the specified directory and returns the full output or error.
+// If ctx can be cancelled the command runs in its own process group, which is killed (and ctx's error
+// returned) if it is.
+func (d *CommandLineExecutor) RunInDirContext(ctx context.Context, cmd, dir string) (string, error) {
+	result, err := d.Exec(ctx, Cmd{Shell: cmd, Dir: dir})
+	if result == nil {
+		return "", err
+	}
+
+	return result.Output, err
+}
+
+// Exec runs a command, outputting to terminal unless silent, and returns its result.
+// A non-nil result is returned if the command started, even if it then failed.
+func (d *CommandLineExecutor) Exec(ctx context.Context, cmd Cmd) (*CmdResult, error) {
 	// you can uncomment this below if you want to see exactly the commands being run
 	// fmt.Println("▶️", cmd).
 
-	command := exec.Command("sh", "-c", cmd)
+	var command *exec.Cmd
+	if len(cmd.Args) > 0 {
+		command = exec.Command(cmd.Args[0], cmd.Args[1:]...)
+	} else {
+		command = exec.Command("sh", "-c", cmd.Shell)
+	}
 
-	command.Dir = dir
+	command.Dir = cmd.Dir
 
-	var outBuf bytes.Buffer
+	if len(cmd.Env) > 0 {
+		command.Env = append(os.Environ(), envList(cmd.Env)...)
+	}
 
-	if silent {
-		command.Stdout = &outBuf
-		command.Stderr = &outBuf
-	} else if writer != nil {
-		command.Stdout = io.MultiWriter(os.Stdout, &outBuf, writer)
-		command.Stderr = io.MultiWriter(os.Stderr, &outBuf, writer)
-	} else {
-		command.Stdout = io.MultiWriter(os.Stdout, &outBuf)
-		command.Stderr = io.MultiWriter(os.Stderr, &outBuf)
+	// Commands that can be cancelled get their own process group so that everything they start can be killed with
+	// them. Others (make, docker build etc) stay in the foreground process group, so Ctrl-C in a terminal reaches them.
+	if ctx.Done() != nil {
+		setProcessGroup(command)
 	}
 
-	runErr := command.Run()
+	var stdoutBuf, stderrBuf bytes.Buffer
+
+	// stdout and stderr are copied concurrently, so the combined output needs a lock.
+	outBuf := &lockedBuffer{}
+
+	stdout := []io.Writer{&stdoutBuf, outBuf}
+	stderr := []io.Writer{&stderrBuf, outBuf}
 
-	outStr := outBuf.String()
+	if !d.silent {
+		stdout = append(stdout, os.Stdout)
+		stderr = append(stderr, os.Stderr)
+
+		if d.writer != nil {
+			stdout = append(stdout, d.writer)
+			stderr = append(stderr, d.writer)
+		}
+	}
+
+	command.Stdout = io.MultiWriter(stdout...)
+	command.Stderr = io.MultiWriter(stderr...)
+
+	if err := ctx.Err(); err != nil {
+		return nil, errors.Wrap(err, "command was cancelled")
+	}
+
+	start := time.Now()
+
+	if err := command.Start(); err != nil {
+		return nil, errors.Wrap(err, "failed to Start command")
+	}
+
+	done := make(chan error, 1)
+
+	go func() {
+		done <- command.Wait()
+	}()
+
+	var runErr error
+	cancelled := false
+
+	select {
+	case runErr = <-done:
+	case <-ctx.Done():
+		killProcessGroup(command)
+		runErr = <-done
+		cancelled = true
+	}
+
+	result := &CmdResult{
+		Stdout:   stdoutBuf.String(),
+		Stderr:   stderrBuf.String(),
+		Output:   outBuf.String(),
+		ExitCode: command.ProcessState.ExitCode(),
+		Duration: time.Since(start),
+	}
+
+	if cancelled {
+		return result, errors.Wrap(ctx.Err(), "command was cancelled")
+	}
 
 	if runErr != nil {
-		return outStr, errors.Wrap(runErr, "failed to Run command")
+		return result, errors.Wrap(runErr, "failed to Run command")
+	}
+
+	return result, nil
+}
+
+// envList converts an env map into KEY=value pairs, sorted so that commands are deterministic.
+func envList(env map[string]string) []string {
+	list := make([]string, 0, len(env))
+	for k, v := range env {
+		list = append(list, fmt.Sprintf("%s=%s", k, v))
+	}
+
+	sort.Strings(list)
+
+	return list
+}
+
+// quoteArg quotes an argument for display if it contains anything a shell would interpret.
+func quoteArg(arg string) string {
+	if arg == "" {
+		return "''"
 	}
 
-	return outStr, nil
+	safe := strings.IndexFunc(arg, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune("-_./=:,@%+", r))
+	}) == -1
+
+	if safe {
+		return arg
+	}
+
+	return "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+}
+
+type lockedBuffer struct {
+	lock sync.Mutex
+	buf  bytes.Buffer
+}
+
+func (l *lockedBuffer) Write(p []byte) (int, error) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	return l.buf.Write(p)
+}
+
+func (l *lockedBuffer) String() string {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	return l.buf.String()
 }
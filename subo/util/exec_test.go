@@ -2,9 +2,16 @@ package util
 
 import (
 	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestCommandRunner_Run(t *testing.T) {
@@ -71,3 +78,97 @@ func TestCommandRunner_Run(t *testing.T) {
 		})
 	}
 }
+
+func TestCommandRunner_RunInDirContext_Cancelled(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+
+	// The backgrounded sleep keeps the output pipe open, so this only returns promptly if the whole process group is killed.
+	_, err := NewCommandLineExecutor(SilentOutput, nil).RunInDirContext(ctx, "sleep 10 & sleep 10", "")
+
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(start), 5*time.Second)
+}
+
+func TestCommandLineExecutor_Exec(t *testing.T) {
+	runner := NewCommandLineExecutor(SilentOutput, nil)
+
+	t.Run("separates stdout and stderr", func(t *testing.T) {
+		result, err := runner.Exec(context.Background(), ShellCmd("echo out; echo err >&2"))
+
+		assert.NoError(t, err)
+		assert.Equal(t, "out\n", result.Stdout)
+		assert.Equal(t, "err\n", result.Stderr)
+		assert.ElementsMatch(t, []string{"out", "err"}, strings.Fields(result.Output))
+		assert.Equal(t, 0, result.ExitCode)
+	})
+
+	t.Run("returns the exit code of a failed command", func(t *testing.T) {
+		result, err := runner.Exec(context.Background(), ShellCmd("echo failing >&2; exit 3"))
+
+		assert.Error(t, err)
+		assert.Equal(t, 3, result.ExitCode)
+		assert.Equal(t, "failing\n", result.Stderr)
+	})
+
+	t.Run("passes arguments without a shell", func(t *testing.T) {
+		dir := filepath.Join(t.TempDir(), "with space")
+		require.NoError(t, os.Mkdir(dir, 0700))
+
+		result, err := runner.Exec(context.Background(), Cmd{Args: []string{"pwd"}, Dir: dir})
+		assert.NoError(t, err)
+		assert.Equal(t, dir, strings.TrimSpace(result.Stdout))
+
+		result, err = runner.Exec(context.Background(), ArgsCmd("echo", "$HOME", "it's"))
+		assert.NoError(t, err)
+		assert.Equal(t, "$HOME it's\n", result.Stdout)
+	})
+
+	t.Run("adds env to the environment", func(t *testing.T) {
+		result, err := runner.Exec(context.Background(), Cmd{Shell: "echo $SUBO_TEST_VAR", Env: map[string]string{"SUBO_TEST_VAR": "hello"}})
+
+		assert.NoError(t, err)
+		assert.Equal(t, "hello\n", result.Stdout)
+	})
+
+	t.Run("fails to start a missing program", func(t *testing.T) {
+		result, err := runner.Exec(context.Background(), ArgsCmd("subo-definitely-not-a-program"))
+
+		assert.Error(t, err)
+		assert.Nil(t, result)
+	})
+}
+
+func TestCmd_String(t *testing.T) {
+	assert.Equal(t, "echo hello | tr a-z A-Z", ShellCmd("echo hello | tr a-z A-Z").String())
+	assert.Equal(t, "docker run --mount type=bind,source=/a,target=/b img", ArgsCmd("docker", "run", "--mount", "type=bind,source=/a,target=/b", "img").String())
+	assert.Equal(t, `cp '/my files/a' 'it'\''s' ''`, ArgsCmd("cp", "/my files/a", "it's", "").String())
+}
+
+func TestRecordingRunner(t *testing.T) {
+	runner := &RecordingRunner{
+		Handler: func(ctx context.Context, cmd Cmd) (*CmdResult, error) {
+			if cmd.Dir == "fail" {
+				return &CmdResult{Output: "nope", ExitCode: 1}, errors.New("command failed")
+			}
+
+			return &CmdResult{Output: "ok"}, nil
+		},
+	}
+
+	out, err := runner.RunInDir("make build", "project")
+	assert.NoError(t, err)
+	assert.Equal(t, "ok", out)
+
+	out, err = runner.RunInDir("make build", "fail")
+	assert.Error(t, err)
+	assert.Equal(t, "nope", out)
+
+	_, err = runner.Exec(context.Background(), ArgsCmd("git", "status"))
+	assert.NoError(t, err)
+
+	assert.Equal(t, []string{"make build", "make build", "git status"}, runner.Strings())
+	assert.Equal(t, "fail", runner.Cmds()[1].Dir)
+}
@@ -0,0 +1,35 @@
+//go:build !windows
+// +build !windows
+
+package util
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCommandLineExecutor_Exec_ProcessGroup(t *testing.T) {
+	pgid := func(ctx context.Context) int {
+		result, err := NewCommandLineExecutor(SilentOutput, nil).Exec(ctx, ShellCmd("ps -o pgid= -p $$"))
+		require.NoError(t, err)
+
+		id, err := strconv.Atoi(strings.TrimSpace(result.Stdout))
+		require.NoError(t, err)
+
+		return id
+	}
+
+	// Commands that can't be cancelled stay in subo's process group, so they get the terminal's signals.
+	assert.Equal(t, syscall.Getpgrp(), pgid(context.Background()))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	assert.NotEqual(t, syscall.Getpgrp(), pgid(ctx))
+}
@@ -2,6 +2,7 @@ package util
 
 import (
 	"fmt"
+	"os"
 )
 
 // FriendlyLogger describes a logger designed to provide friendly output for interactive CLI purposes.
@@ -22,6 +23,19 @@ func (p *PrintLogger) LogDone(msg string)  { LogDone(msg) }
 func (p *PrintLogger) LogFail(msg string)  { LogFail(msg) }
 func (p *PrintLogger) LogWarn(msg string)  { LogWarn(msg) }
 
+// StderrLogger is a FriendlyLogger which writes to stderr, leaving stdout free for machine-readable output.
+type StderrLogger struct{}
+
+func (s *StderrLogger) LogInfo(msg string)  { logStderr(fmt.Sprintf("ℹ️  %s", msg)) }
+func (s *StderrLogger) LogStart(msg string) { logStderr(fmt.Sprintf("⏩ START: %s", msg)) }
+func (s *StderrLogger) LogDone(msg string)  { logStderr(fmt.Sprintf("✅ DONE: %s", msg)) }
+func (s *StderrLogger) LogFail(msg string)  { logStderr(fmt.Sprintf("🚫 FAILED: %s", msg)) }
+func (s *StderrLogger) LogWarn(msg string)  { logStderr(fmt.Sprintf("⚠️ WARNING: %s", msg)) }
+
+func logStderr(msg string) {
+	fmt.Fprintln(os.Stderr, msg)
+}
+
 // Keeping it DRY.
 func log(msg string) {
 	fmt.Println(msg)
@@ -0,0 +1,66 @@
+package util
+
+import (
+	"context"
+	"sync"
+)
+
+// RecordingRunner is a CommandRunner for tests which records the commands it is asked to run rather than running them.
+type RecordingRunner struct {
+	// Handler decides the result of each command. If nil, every command succeeds with no output.
+	Handler func(ctx context.Context, cmd Cmd) (*CmdResult, error)
+
+	lock sync.Mutex
+	cmds []Cmd
+}
+
+// Run records a shell command.
+func (r *RecordingRunner) Run(cmd string) (string, error) {
+	return r.RunInDirContext(context.Background(), cmd, "")
+}
+
+// RunInDir records a shell command run in dir.
+func (r *RecordingRunner) RunInDir(cmd, dir string) (string, error) {
+	return r.RunInDirContext(context.Background(), cmd, dir)
+}
+
+// RunInDirContext records a shell command run in dir.
+func (r *RecordingRunner) RunInDirContext(ctx context.Context, cmd, dir string) (string, error) {
+	result, err := r.Exec(ctx, Cmd{Shell: cmd, Dir: dir})
+	if result == nil {
+		return "", err
+	}
+
+	return result.Output, err
+}
+
+// Exec records a command and returns the result from Handler.
+func (r *RecordingRunner) Exec(ctx context.Context, cmd Cmd) (*CmdResult, error) {
+	r.lock.Lock()
+	r.cmds = append(r.cmds, cmd)
+	r.lock.Unlock()
+
+	if r.Handler == nil {
+		return &CmdResult{}, nil
+	}
+
+	return r.Handler(ctx, cmd)
+}
+
+// Cmds returns the commands that have been recorded, in the order they were run.
+func (r *RecordingRunner) Cmds() []Cmd {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	return append([]Cmd{}, r.cmds...)
+}
+
+// Strings returns the recorded commands as they would be typed into a shell.
+func (r *RecordingRunner) Strings() []string {
+	strs := []string{}
+	for _, c := range r.Cmds() {
+		strs = append(strs, c.String())
+	}
+
+	return strs
+}